@@ -0,0 +1,425 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+	"sync/atomic"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// 口令加密: 使用口令派生AES秘钥, GCM认证加密
+//
+//	秘钥派生: master = PBKDF2-HMAC-SHA256(password, salt, iterations, 32)
+//	         key = HKDF-SHA256(master, salt=空, info="go-utils/aes-gcm", keySize)
+//	密文格式: 版本(1字节) | 派生算法(1字节) | 迭代次数(4字节) | 秘钥长度(1字节) | 盐值长度(1字节) | 盐值 | nonce(12字节) | 密文 | 认证标签(16字节)
+//	 - 多字节整数为大端序, nonce 之前的头部作为附加数据
+//	 - 解密时按密文头部的参数派生秘钥, 修改迭代次数等参数后仍可解密历史数据
+//	 - 头部未经认证, 解密时迭代次数不能超过 WithKDFMaxIterations 设置的值, 防止伪造密文消耗资源
+
+const (
+	// passwordVersion 口令加密密文版本
+	passwordVersion = 1
+
+	// passwordKDF 派生算法: PBKDF2-HMAC-SHA256 + HKDF-SHA256
+	passwordKDF = 1
+
+	// passwordKeyInfo HKDF 派生AES秘钥的 info
+	passwordKeyInfo = "go-utils/aes-gcm"
+
+	// passwordMaxIterations 解密时允许的最大迭代次数, 防止伪造密文消耗资源
+	passwordMaxIterations = 10_000_000
+)
+
+// KDFParams 口令派生秘钥参数, 其它语言按此参数派生相同的秘钥并解密
+type KDFParams struct {
+	Version    int    `json:"version"`     // 密文版本
+	KDF        string `json:"kdf"`         // 派生主秘钥算法: PBKDF2-HMAC-SHA256
+	Iterations int    `json:"iterations"`  // PBKDF2 迭代次数
+	Salt       []byte `json:"salt"`        // 盐值
+	MasterSize int    `json:"master_size"` // 主秘钥长度
+	SubKDF     string `json:"sub_kdf"`     // 派生子秘钥算法: HKDF-SHA256, salt 为空
+	Info       string `json:"info"`        // 派生AES秘钥的 HKDF info
+	KeySize    int    `json:"key_size"`    // AES秘钥长度
+	Mode       string `json:"mode"`        // 加密模式: AES-GCM
+	NonceSize  int    `json:"nonce_size"`  // nonce 长度, 放在头部之后
+	TagSize    int    `json:"tag_size"`    // 认证标签长度, 放在密文末尾
+}
+
+// newKDFParams 使用默认算法的派生参数
+func newKDFParams(salt []byte, iterations, keySize int) KDFParams {
+	return KDFParams{
+		Version:    passwordVersion,
+		KDF:        "PBKDF2-HMAC-SHA256",
+		Iterations: iterations,
+		Salt:       salt,
+		MasterSize: sha256.Size,
+		SubKDF:     "HKDF-SHA256",
+		Info:       passwordKeyInfo,
+		KeySize:    keySize,
+		Mode:       "AES-GCM",
+		NonceSize:  12,
+		TagSize:    16,
+	}
+}
+
+// header 密文头部
+func (p KDFParams) header() []byte {
+	b := make([]byte, 0, 8+len(p.Salt))
+	b = append(b, passwordVersion, passwordKDF)
+	b = binary.BigEndian.AppendUint32(b, uint32(p.Iterations))
+	b = append(b, byte(p.KeySize), byte(len(p.Salt)))
+	return append(b, p.Salt...)
+}
+
+// equal 派生参数是否相同
+func (p KDFParams) equal(o KDFParams) bool {
+	return p.Iterations == o.Iterations && p.KeySize == o.KeySize && bytes.Equal(p.Salt, o.Salt)
+}
+
+// ParseKDFParams 从 PasswordCipher 加密的密文头部解析派生参数
+func ParseKDFParams(data []byte) (KDFParams, error) {
+	if len(data) < 8 {
+		return KDFParams{}, errors.New("密文太短")
+	}
+	if data[0] != passwordVersion {
+		return KDFParams{}, errors.Errorf("不支持的密文版本: %d", data[0])
+	}
+	if data[1] != passwordKDF {
+		return KDFParams{}, errors.Errorf("不支持的派生算法: %d", data[1])
+	}
+
+	iterations := binary.BigEndian.Uint32(data[2:6])
+	if iterations == 0 || iterations > passwordMaxIterations {
+		return KDFParams{}, errors.Errorf("迭代次数错误: %d", iterations)
+	}
+	keySize := int(data[6])
+	switch keySize {
+	default:
+		return KDFParams{}, errors.Errorf("AES秘钥长度错误: %d", keySize)
+	case 16, 24, 32:
+	}
+	saltSize := int(data[7])
+	if len(data) < 8+saltSize {
+		return KDFParams{}, errors.New("密文太短")
+	}
+
+	return newKDFParams(bytes.Clone(data[8:8+saltSize]), int(iterations), keySize), nil
+}
+
+// PasswordCipher 口令加密器, 可并发使用
+type PasswordCipher struct {
+	password      []byte
+	params        KDFParams
+	master        []byte // 主秘钥
+	aead          cipher.AEAD
+	maxIterations int                            // 解密时允许的最大迭代次数
+	last          atomic.Pointer[PasswordCipher] // 最近一次解密时按不同参数派生的加密器
+}
+
+// PasswordOption 口令加密器配置项
+type PasswordOption func(*passwordOptions)
+
+type passwordOptions struct {
+	iterations    int
+	maxIterations int
+	keySize       int
+	saltSize      int
+}
+
+// WithKDFIterations 设置 PBKDF2 迭代次数, 默认: 600000
+func WithKDFIterations(iterations int) PasswordOption {
+	return func(o *passwordOptions) {
+		o.iterations = iterations
+	}
+}
+
+// WithKDFMaxIterations 设置解密时允许的最大迭代次数, 默认: 加密的迭代次数
+//
+//	降低迭代次数后解密历史数据, 需设置为历史数据的迭代次数; 不能超过10000000
+func WithKDFMaxIterations(iterations int) PasswordOption {
+	return func(o *passwordOptions) {
+		o.maxIterations = iterations
+	}
+}
+
+// WithKDFKeySize 设置AES秘钥长度: 16、24或32字节, 默认: 32
+func WithKDFKeySize(size int) PasswordOption {
+	return func(o *passwordOptions) {
+		o.keySize = size
+	}
+}
+
+// WithKDFSaltSize 设置随机生成的盐值长度, 默认: 16
+func WithKDFSaltSize(size int) PasswordOption {
+	return func(o *passwordOptions) {
+		o.saltSize = size
+	}
+}
+
+// AESFromPassword 使用口令派生秘钥的AES加密器
+//
+//	password 口令
+//	salt 盐值: 为空时随机生成; 盐值及派生参数写入密文头部, 解密时从密文头部读取
+func AESFromPassword(password, salt string, opts ...PasswordOption) (*PasswordCipher, error) {
+	if password == "" {
+		return nil, errors.New("口令不能为空")
+	}
+
+	cfg := passwordOptions{iterations: 600000, keySize: 32, saltSize: 16}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.iterations <= 0 || cfg.iterations > passwordMaxIterations {
+		return nil, errors.Errorf("迭代次数只能是1到%d: %d", passwordMaxIterations, cfg.iterations)
+	}
+	if cfg.maxIterations == 0 {
+		cfg.maxIterations = cfg.iterations
+	} else if cfg.maxIterations < cfg.iterations || cfg.maxIterations > passwordMaxIterations {
+		return nil, errors.Errorf("最大迭代次数只能是%d到%d: %d", cfg.iterations, passwordMaxIterations, cfg.maxIterations)
+	}
+	switch cfg.keySize {
+	default:
+		return nil, errors.Errorf("AES秘钥的长度只能是16、24或32字节: %d", cfg.keySize)
+	case 16, 24, 32:
+	}
+
+	s := []byte(salt)
+	if len(s) == 0 {
+		if cfg.saltSize < 8 || cfg.saltSize > 255 {
+			return nil, errors.Errorf("盐值长度只能是8到255字节: %d", cfg.saltSize)
+		}
+		s = make([]byte, cfg.saltSize)
+		if _, err := io.ReadFull(rand.Reader, s); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	} else if len(s) > 255 {
+		return nil, errors.Errorf("盐值长度不能超过255字节: %d", len(s))
+	}
+
+	p, err := newPasswordCipher([]byte(password), newKDFParams(s, cfg.iterations, cfg.keySize))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	p.maxIterations = cfg.maxIterations
+	return p, nil
+}
+
+// newPasswordCipher 按派生参数派生秘钥
+func newPasswordCipher(password []byte, params KDFParams) (*PasswordCipher, error) {
+	master, err := PBKDF2(password, params.Salt, params.Iterations, params.MasterSize)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	key, err := HKDF(master, nil, []byte(params.Info), params.KeySize)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &PasswordCipher{password: password, params: params, master: master, aead: aead}, nil
+}
+
+// Params 派生参数, 用于其它语言派生相同的秘钥
+func (p *PasswordCipher) Params() KDFParams {
+	params := p.params
+	params.Salt = append([]byte(nil), p.params.Salt...)
+	return params
+}
+
+// Salt 盐值
+func (p *PasswordCipher) Salt() []byte {
+	return append([]byte(nil), p.params.Salt...)
+}
+
+// SubKey 使用 HKDF-SHA256 从主秘钥派生子秘钥, 如: 签名秘钥
+//
+//	info 用途, 不同用途派生不同的秘钥; 不能使用 "go-utils/aes-gcm"
+func (p *PasswordCipher) SubKey(info string, size int) ([]byte, error) {
+	if info == passwordKeyInfo {
+		return nil, errors.Errorf("info 不能使用: %s", passwordKeyInfo)
+	}
+	return HKDF(p.master, nil, []byte(info), size)
+}
+
+// Cipher 使用派生的AES秘钥创建 Cipher, 用于其它加密模式及流式加密
+//
+//	密文中不包含盐值, 解密时需使用相同的口令及 Salt() 派生秘钥
+func (p *PasswordCipher) Cipher(opts ...CipherOption) (*Cipher, error) {
+	key, err := HKDF(p.master, nil, []byte(p.params.Info), p.params.KeySize)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return NewCipher(string(key), aes.NewCipher, opts...)
+}
+
+// Encrypt 加密: 密文头部包含派生参数及盐值
+func (p *PasswordCipher) Encrypt(data []byte) ([]byte, error) {
+	header := p.params.header()
+	out := make([]byte, len(header)+p.aead.NonceSize(), len(header)+p.aead.NonceSize()+len(data)+p.aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return p.aead.Seal(out, nonce, data, header), nil
+}
+
+// Decrypt 解密: 按密文头部的派生参数派生秘钥, 参数与当前加密器不同时重新派生
+//
+//	缓存最近一次重新派生的秘钥, 连续解密相同参数的历史数据时不重复派生
+func (p *PasswordCipher) Decrypt(data []byte) ([]byte, error) {
+	params, err := ParseKDFParams(data)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if params.Iterations > p.maxIterations {
+		return nil, errors.Errorf("迭代次数超过最大值: %d > %d", params.Iterations, p.maxIterations)
+	}
+
+	c := p
+	if !params.equal(p.params) {
+		if last := p.last.Load(); last != nil && params.equal(last.params) {
+			c = last
+		} else {
+			if c, err = newPasswordCipher(p.password, params); err != nil {
+				return nil, errors.Wrap(err)
+			}
+			p.last.Store(c)
+		}
+	}
+
+	headerSize := 8 + len(params.Salt)
+	data = data[headerSize:]
+	if len(data) < c.aead.NonceSize()+c.aead.Overhead() {
+		return nil, errors.New("密文太短")
+	}
+	plain, err := c.aead.Open(nil, data[:c.aead.NonceSize()], data[c.aead.NonceSize():], params.header())
+	if err != nil {
+		return nil, errors.Wrap(err, "口令错误或密文被篡改")
+	}
+	return plain, nil
+}
+
+// EncryptString 加密字符串
+//
+//	encode 密文编码: base64.StdEncoding.EncodeToString 等
+func (p *PasswordCipher) EncryptString(data string, encode EncodeToString) (string, error) {
+	encrypt, err := p.Encrypt([]byte(data))
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return encode(encrypt), nil
+}
+
+// DecryptString 解密字符串
+//
+//	decode 密文解码: base64.StdEncoding.DecodeString 等
+func (p *PasswordCipher) DecryptString(encrypt string, decode DecodeString) (string, error) {
+	data, err := decode(encrypt)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	plain, err := p.Decrypt(data)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return string(plain), nil
+}
+
+// PBKDF2 使用 HMAC-h 派生 keyLen 字节的秘钥(RFC 8018)
+//
+//	iterations 迭代次数: 不能小于1
+//	keyLen 秘钥长度: 1到 (2^32-1)*哈希长度
+//	h 哈希函数: 为nil时使用 sha256.New
+func PBKDF2(password, salt []byte, iterations, keyLen int, h ...func() hash.Hash) ([]byte, error) {
+	if iterations < 1 {
+		return nil, errors.Errorf("PBKDF2 迭代次数不能小于1: %d", iterations)
+	}
+
+	newHash := sha256.New
+	if len(h) > 0 && h[0] != nil {
+		newHash = h[0]
+	}
+
+	prf := hmac.New(newHash, password)
+	size := prf.Size()
+	if keyLen <= 0 || uint64(keyLen) > uint64(1<<32-1)*uint64(size) {
+		return nil, errors.Errorf("PBKDF2 秘钥长度只能是1到%d: %d", uint64(1<<32-1)*uint64(size), keyLen)
+	}
+	blocks := (keyLen + size - 1) / size
+
+	var (
+		dk  = make([]byte, 0, blocks*size)
+		buf = make([]byte, 4)
+		u   = make([]byte, size)
+	)
+	for block := 1; block <= blocks; block++ {
+		// U1 = PRF(password, salt || INT(block))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-size:]
+		copy(u, t)
+
+		// T = U1 ^ U2 ^ ... ^ Uc
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen], nil
+}
+
+// HKDF 使用 HKDF-SHA256 派生 keyLen 字节的秘钥(RFC 5869)
+//
+//	secret 原始秘钥
+//	salt 盐值: 可以为空
+//	info 用途
+func HKDF(secret, salt, info []byte, keyLen int) ([]byte, error) {
+	if keyLen <= 0 || keyLen > 255*sha256.Size {
+		return nil, errors.Errorf("HKDF 秘钥长度只能是1到%d: %d", 255*sha256.Size, keyLen)
+	}
+
+	// 提取: PRK = HMAC(salt, secret)
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	// 扩展: T(n) = HMAC(PRK, T(n-1) || info || n)
+	var (
+		expand = hmac.New(sha256.New, prk)
+		okm    = make([]byte, 0, keyLen+sha256.Size)
+		t      []byte
+	)
+	for n := byte(1); len(okm) < keyLen; n++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{n})
+		t = expand.Sum(t[:0])
+		okm = append(okm, t...)
+	}
+	return okm[:keyLen], nil
+}
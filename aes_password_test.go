@@ -0,0 +1,239 @@
+package utils_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		salt       string
+		iterations int
+		keyLen     int
+		sha1       bool
+		want       string
+		wantErr    bool
+	}{
+		// RFC 7914 11.
+		{name: "001", password: "passwd", salt: "salt", iterations: 1, keyLen: 64, want: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		// RFC 6070
+		{name: "002", password: "password", salt: "salt", iterations: 2, keyLen: 20, sha1: true, want: "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{name: "003", password: "passwordPASSWORDpassword", salt: "saltSALTsaltSALTsaltSALTsaltSALTsalt", iterations: 4096, keyLen: 25, sha1: true, want: "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{name: "004", password: "passwd", salt: "salt", iterations: 0, keyLen: 32, wantErr: true},
+		{name: "005", password: "passwd", salt: "salt", iterations: 1, keyLen: -1, wantErr: true},
+		{name: "006", password: "passwd", salt: "salt", iterations: 1, keyLen: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got []byte
+				err error
+			)
+			if tt.sha1 {
+				got, err = utils.PBKDF2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen, sha1.New)
+			} else {
+				got, err = utils.PBKDF2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PBKDF2() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("PBKDF2() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHKDF(t *testing.T) {
+	hexDecode := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+
+	// RFC 5869 A.1、A.3
+	tests := []struct {
+		name   string
+		secret []byte
+		salt   []byte
+		info   []byte
+		keyLen int
+		want   string
+	}{
+		{name: "001", secret: bytes.Repeat([]byte{0x0b}, 22), salt: hexDecode("000102030405060708090a0b0c"), info: hexDecode("f0f1f2f3f4f5f6f7f8f9"), keyLen: 42, want: "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"},
+		{name: "002", secret: bytes.Repeat([]byte{0x0b}, 22), keyLen: 42, want: "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := utils.HKDF(tt.secret, tt.salt, tt.info, tt.keyLen)
+			if err != nil {
+				t.Fatalf("HKDF() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("HKDF() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := utils.HKDF([]byte("secret"), nil, nil, 255*32+1); err == nil {
+		t.Errorf("HKDF() 秘钥长度超出 error = nil")
+	}
+}
+
+func TestAESFromPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		opts     []utils.PasswordOption
+		data     string
+		wantErr  bool
+	}{
+		{name: "001", password: "123456", opts: []utils.PasswordOption{utils.WithKDFIterations(1000)}, data: "hello"},
+		{name: "002", password: "123456", salt: "fixed-salt", opts: []utils.PasswordOption{utils.WithKDFIterations(1000), utils.WithKDFKeySize(16)}, data: ""},
+		{name: "003", password: "123456", opts: []utils.PasswordOption{utils.WithKDFIterations(1000), utils.WithKDFKeySize(24), utils.WithKDFSaltSize(32)}, data: "中文"},
+		{name: "004", password: "", wantErr: true},
+		{name: "005", password: "123456", opts: []utils.PasswordOption{utils.WithKDFKeySize(20)}, wantErr: true},
+		{name: "006", password: "123456", opts: []utils.PasswordOption{utils.WithKDFIterations(0)}, wantErr: true},
+		{name: "007", password: "123456", opts: []utils.PasswordOption{utils.WithKDFSaltSize(4)}, wantErr: true},
+		{name: "008", password: "123456", opts: []utils.PasswordOption{utils.WithKDFIterations(1000), utils.WithKDFMaxIterations(500)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := utils.AESFromPassword(tt.password, tt.salt, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AESFromPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			encrypt, err := p.EncryptString(tt.data, base64.StdEncoding.EncodeToString)
+			if err != nil {
+				t.Fatalf("EncryptString() error = %v", err)
+			}
+			got, err := p.DecryptString(encrypt, base64.StdEncoding.DecodeString)
+			if err != nil {
+				t.Fatalf("DecryptString() error = %v", err)
+			}
+			if got != tt.data {
+				t.Errorf("解密后数据不等于加密前数据 got = %v, want %v", got, tt.data)
+			}
+		})
+	}
+
+	p, _ := utils.AESFromPassword("123456", "", utils.WithKDFIterations(1000))
+	encrypt, _ := p.Encrypt([]byte("hello"))
+
+	t.Run("Params", func(t *testing.T) {
+		params, err := utils.ParseKDFParams(encrypt)
+		if err != nil {
+			t.Fatalf("ParseKDFParams() error = %v", err)
+		}
+		want := p.Params()
+		if params.Iterations != 1000 || params.KeySize != 32 || !bytes.Equal(params.Salt, p.Salt()) || len(params.Salt) != 16 {
+			t.Errorf("ParseKDFParams() = %+v, want %+v", params, want)
+		}
+
+		// 按派生参数派生相同的秘钥解密
+		master, _ := utils.PBKDF2([]byte("123456"), params.Salt, params.Iterations, params.MasterSize)
+		key, _ := utils.HKDF(master, nil, []byte(params.Info), params.KeySize)
+		c, _ := utils.AES(string(key))
+		header := len(encrypt) - len("hello") - params.NonceSize - params.TagSize
+		got, err := c.DecryptGCM(encrypt[header:], encrypt[:header])
+		if err != nil || string(got) != "hello" {
+			t.Errorf("DecryptGCM() = %v, %v, want hello", string(got), err)
+		}
+	})
+
+	t.Run("Decrypt", func(t *testing.T) {
+		// 参数不同: 按密文头部重新派生
+		other, _ := utils.AESFromPassword("123456", "", utils.WithKDFIterations(2000), utils.WithKDFKeySize(16))
+		if got, err := other.Decrypt(encrypt); err != nil || string(got) != "hello" {
+			t.Errorf("Decrypt() = %v, %v, want hello", string(got), err)
+		}
+
+		// 迭代次数超过最大值: 不派生秘钥
+		high, _ := utils.AESFromPassword("123456", "", utils.WithKDFIterations(3000))
+		highEncrypt, _ := high.Encrypt([]byte("hello"))
+		if _, err := p.Decrypt(highEncrypt); err == nil {
+			t.Errorf("Decrypt() 迭代次数超过最大值 error = nil")
+		}
+		forge := bytes.Clone(encrypt)
+		binary.BigEndian.PutUint32(forge[2:6], 10_000_000)
+		start := time.Now()
+		if _, err := p.Decrypt(forge); err == nil || time.Since(start) > time.Second {
+			t.Errorf("Decrypt() 伪造迭代次数 error = %v, elapsed = %v", err, time.Since(start))
+		}
+
+		// 设置最大迭代次数后解密历史数据, 重复解密使用缓存的秘钥
+		low, _ := utils.AESFromPassword("123456", "", utils.WithKDFIterations(1000), utils.WithKDFMaxIterations(3000))
+		for range 2 {
+			if got, err := low.Decrypt(highEncrypt); err != nil || string(got) != "hello" {
+				t.Errorf("Decrypt() = %v, %v, want hello", string(got), err)
+			}
+		}
+		if got, err := low.Decrypt(encrypt); err != nil || string(got) != "hello" {
+			t.Errorf("Decrypt() = %v, %v, want hello", string(got), err)
+		}
+
+		// 口令错误
+		wrong, _ := utils.AESFromPassword("654321", "", utils.WithKDFIterations(1000))
+		if _, err := wrong.Decrypt(encrypt); err == nil {
+			t.Errorf("Decrypt() 口令错误 error = nil")
+		}
+
+		// 篡改头部
+		tamper := bytes.Clone(encrypt)
+		tamper[6] = 16
+		if _, err := p.Decrypt(tamper); err == nil {
+			t.Errorf("Decrypt() 篡改头部 error = nil")
+		}
+
+		// 密文太短
+		if _, err := p.Decrypt(encrypt[:20]); err == nil {
+			t.Errorf("Decrypt() 密文太短 error = nil")
+		}
+	})
+
+	t.Run("SubKey", func(t *testing.T) {
+		sign, err := p.SubKey("sign", 32)
+		if err != nil || len(sign) != 32 {
+			t.Fatalf("SubKey() = %x, %v", sign, err)
+		}
+		again, _ := p.SubKey("sign", 32)
+		other, _ := p.SubKey("mac", 32)
+		if !bytes.Equal(sign, again) || bytes.Equal(sign, other) {
+			t.Errorf("SubKey() 相同用途应派生相同秘钥, 不同用途应派生不同秘钥")
+		}
+		if _, err = p.SubKey(p.Params().Info, 32); err == nil {
+			t.Errorf("SubKey() 使用加密秘钥的 info error = nil")
+		}
+	})
+
+	t.Run("Cipher", func(t *testing.T) {
+		c, err := p.Cipher(utils.WithRandIV(true))
+		if err != nil {
+			t.Fatalf("Cipher() error = %v", err)
+		}
+		var buf, got bytes.Buffer
+		if err = c.EncryptStream(&buf, bytes.NewReader([]byte("hello")), utils.GCM); err != nil {
+			t.Fatalf("EncryptStream() error = %v", err)
+		}
+
+		// 相同口令及盐值派生相同的秘钥
+		same, _ := utils.AESFromPassword("123456", string(p.Salt()), utils.WithKDFIterations(1000))
+		sc, _ := same.Cipher()
+		if err = sc.DecryptStream(&got, &buf, utils.GCM); err != nil || got.String() != "hello" {
+			t.Errorf("DecryptStream() = %v, %v, want hello", got.String(), err)
+		}
+	})
+}
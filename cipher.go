@@ -14,9 +14,11 @@ import (
 // 	（c）计算器模式（Counter，CTR）；
 // 	（d）密码反馈模式（Cipher FeedBack，CFB）；
 // 	（e）输出反馈模式（Output FeedBack，OFB）。
+// 	（f）伽罗瓦/计数器模式（Galois/Counter Mode，GCM），认证加密，密文被篡改时解密失败。
 // 2. AES|DES是对称分组加密算法。AES每组长度为128bits，即16字节；DES每组长度为64bits，即8字节。
 // 3. AES秘钥的长度只能是16、24或32字节，分别对应三种AES，即AES-128, AES-192和AES-256，三者的区别是加密的轮数不同；DES秘钥的长度只能是8字节；3DES秘钥的长度只能是24字节。
 // 4. IV长度: AES的IV长度只能是16字节, DES的IV长度只能是8字节。
+// 5. GCM仅支持AES, 每次加密随机生成12字节nonce放在密文头部, 不使用设置的IV。
 
 type Cipher struct {
 	key      []byte // AES秘钥的长度只能是16、24或32字节，分别对应三种AES，即AES-128, AES-192和AES-256；DES秘钥的长度只能是8字节；3DES秘钥的长度只能是24字节。
@@ -452,6 +454,70 @@ func (c *Cipher) DecryptOFB(data []byte, unPadding UnPadding) ([]byte, error) {
 	return unPadding(decrypt)
 }
 
+// EncryptGCM 加密
+//
+//	additionalData 附加数据: 参与认证但不加密, 解密时需提供相同的附加数据; 可为nil
+//	每次加密随机生成 nonce(12字节) 放在密文头部, 不使用设置的IV(重复使用 nonce 会破坏 GCM 的安全性)
+func (c *Cipher) EncryptGCM(data, additionalData []byte) ([]byte, error) {
+	aead, err := c.gcm()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	// 初始化加密数据接收切片
+	nonceSize := aead.NonceSize()
+	encrypt := make([]byte, nonceSize, nonceSize+len(data)+aead.Overhead())
+
+	// 随机生成 nonce, 将 nonce 添加到密文开头
+	if _, err := io.ReadFull(rand.Reader, encrypt); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	// 执行加密
+	return aead.Seal(encrypt, encrypt, data, additionalData), nil
+}
+
+// DecryptGCM 解密, 密文被篡改或附加数据不一致时返回错误
+//
+//	additionalData 附加数据: 与加密时的附加数据一致
+func (c *Cipher) DecryptGCM(data, additionalData []byte) ([]byte, error) {
+	aead, err := c.gcm()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	// 判断密文长度
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize+aead.Overhead() {
+		return nil, errors.New("密文太短")
+	}
+
+	// 执行解密并验证
+	decrypt, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return decrypt, nil
+}
+
+// gcm 创建 GCM 认证加密实例
+func (c *Cipher) gcm() (cipher.AEAD, error) {
+	if !c.isSetKey() {
+		return nil, errors.New("请先设置秘钥")
+	}
+
+	// GCM 只支持块大小为16字节的分组密码
+	if c.block.BlockSize() != 16 {
+		return nil, errors.Errorf("GCM模式只支持AES, 当前块大小: %d", c.block.BlockSize())
+	}
+
+	aead, err := cipher.NewGCM(c.block)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return aead, nil
+}
+
 // Encrypt 加密
 //
 //	data 待加密数据
@@ -461,8 +527,9 @@ func (c *Cipher) DecryptOFB(data []byte, unPadding UnPadding) ([]byte, error) {
 //	 - CTR: Encrypt(data, CTR, encode, padding)
 //	 - CFB: Encrypt(data, CFB, encode, padding)
 //	 - OFB: Encrypt(data, OFB, encode, padding)
+//	 - GCM: Encrypt(data, GCM, encode, nil), 无附加数据; 需附加数据请使用 EncryptGCM
 //	encode 编码方法
-//	padding 填充数据方法
+//	padding 填充数据方法: GCM 模式不填充
 func (c *Cipher) Encrypt(data string, mode McryptMode, encode EncodeToString, padding Padding) (string, error) {
 	var (
 		encrypt []byte
@@ -480,6 +547,8 @@ func (c *Cipher) Encrypt(data string, mode McryptMode, encode EncodeToString, pa
 		encrypt, err = c.EncryptCFB([]byte(data), padding)
 	case OFB:
 		encrypt, err = c.EncryptOFB([]byte(data), padding)
+	case GCM:
+		encrypt, err = c.EncryptGCM([]byte(data), nil)
 	default:
 		return "", errors.New("错误的加密模式")
 	}
@@ -500,8 +569,9 @@ func (c *Cipher) Encrypt(data string, mode McryptMode, encode EncodeToString, pa
 //	 - CTR: Decrypt(encrypt, CTR, decode, unPadding)
 //	 - CFB: Decrypt(encrypt, CFB, decode, unPadding)
 //	 - OFB: Decrypt(encrypt, OFB, decode, unPadding)
+//	 - GCM: Decrypt(encrypt, GCM, decode, nil), 无附加数据; 需附加数据请使用 DecryptGCM
 //	decode 解码方法
-//	unPadding 去除填充数据方法
+//	unPadding 去除填充数据方法: GCM 模式不去除填充
 func (c *Cipher) Decrypt(encrypt string, mode McryptMode, decode DecodeString, unPadding UnPadding) (string, error) {
 	ciphertext, err := decode(encrypt)
 	if err != nil {
@@ -521,6 +591,8 @@ func (c *Cipher) Decrypt(encrypt string, mode McryptMode, decode DecodeString, u
 		decrypt, err = c.DecryptCFB(ciphertext, unPadding)
 	case OFB:
 		decrypt, err = c.DecryptOFB(ciphertext, unPadding)
+	case GCM:
+		decrypt, err = c.DecryptGCM(ciphertext, nil)
 	default:
 		return "", errors.New("错误的解密模式")
 	}
@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// 流式加密: 分块读取数据加密或解密, 不将全部数据载入内存, 用于加密大文件
+//
+//	CTR、CFB、OFB: 不填充, 与 EncryptCTR 等方法(填充后加密)的密文不兼容; isRandIV 为true时随机IV放在密文头部
+//	GCM: 分块认证加密, 格式: 盐值(32字节) | nonce前缀(7字节) | 块大小(4字节) | 密文块...
+//	 - 每个流使用独立的秘钥: HKDF-SHA256(秘钥, 盐值, "gcm-stream"), 长度与秘钥相同; 同一秘钥加密大量文件时 nonce 不会重复
+//	 - 每块明文 gcmStreamChunkSize 字节(最后一块可以更短), 加密后增加16字节认证标签
+//	 - 每块 nonce = nonce前缀 | 块序号(4字节) | 最后一块标记(1字节), 头部作为附加数据
+//	 - 密文块被篡改、调换顺序或截断时解密失败
+//	 - 与未使用盐值的旧格式(nonce前缀 | 块大小 | 密文块)不兼容
+
+const (
+	// gcmStreamChunkSize GCM流式加密每块明文长度
+	gcmStreamChunkSize = 64 * 1024
+
+	// gcmStreamSaltSize GCM流式加密派生秘钥的盐值长度
+	gcmStreamSaltSize = 32
+
+	// gcmStreamPrefixSize GCM流式加密 nonce 前缀长度
+	gcmStreamPrefixSize = 7
+
+	// gcmStreamHeaderSize GCM流式加密头部长度: 盐值 + nonce前缀 + 块大小
+	gcmStreamHeaderSize = gcmStreamSaltSize + gcmStreamPrefixSize + 4
+)
+
+// EncryptStream 流式加密: 从 src 分块读取数据, 加密后写入 dst
+//
+//	mode 加密模式: CTR, CFB, OFB, GCM
+func (c *Cipher) EncryptStream(dst io.Writer, src io.Reader, mode McryptMode) error {
+	if mode == GCM {
+		return errors.Wrap(c.encryptGCMStream(dst, src))
+	}
+
+	iv, err := c.streamIV()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 随机生成IV, 将IV值写入密文开头
+	if c.isRandIV {
+		if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+			return errors.Wrap(err)
+		}
+		if _, err = dst.Write(iv); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
+	stream, err := c.stream(mode, iv, true)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	return Read(src, func(_ int, block []byte) error {
+		stream.XORKeyStream(block, block)
+		_, err := dst.Write(block)
+		return err
+	})
+}
+
+// DecryptStream 流式解密: 从 src 分块读取 EncryptStream 加密的数据, 解密后写入 dst
+//
+//	mode 加密模式: CTR, CFB, OFB, GCM
+//	GCM 模式逐块验证并写入, 解密失败时 dst 可能已写入部分数据
+func (c *Cipher) DecryptStream(dst io.Writer, src io.Reader, mode McryptMode) error {
+	if mode == GCM {
+		return errors.Wrap(c.decryptGCMStream(dst, src))
+	}
+
+	iv, err := c.streamIV()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 读取密文开头的IV
+	if c.isRandIV {
+		if _, err = io.ReadFull(src, iv); err != nil {
+			return errors.Wrap(err, "密文太短")
+		}
+	}
+
+	stream, err := c.stream(mode, iv, false)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	return Read(src, func(_ int, block []byte) error {
+		stream.XORKeyStream(block, block)
+		_, err := dst.Write(block)
+		return err
+	})
+}
+
+// streamIV 流式加密使用的IV副本, 不修改 Cipher 的IV
+func (c *Cipher) streamIV() ([]byte, error) {
+	if err := c.check(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if c.isRandIV {
+		return make([]byte, c.block.BlockSize()), nil
+	}
+	return bytes.Clone(c.iv), nil
+}
+
+// stream 创建流式加密模式
+func (c *Cipher) stream(mode McryptMode, iv []byte, encrypt bool) (cipher.Stream, error) {
+	switch mode {
+	case CTR:
+		return cipher.NewCTR(c.block, iv), nil
+	case CFB:
+		if encrypt {
+			return cipher.NewCFBEncrypter(c.block, iv), nil
+		}
+		return cipher.NewCFBDecrypter(c.block, iv), nil
+	case OFB:
+		return cipher.NewOFB(c.block, iv), nil
+	default:
+		return nil, errors.New("流式加密只支持 CTR、CFB、OFB、GCM 模式")
+	}
+}
+
+// gcmStream GCM分块认证加密
+type gcmStream struct {
+	aead   cipher.AEAD
+	header []byte // 头部: 盐值 | nonce前缀 | 块大小
+	nonce  []byte
+	seq    uint32 // 块序号
+}
+
+// newGCMStream 使用头部创建GCM分块认证加密, 使用头部的盐值派生本流的秘钥
+func (c *Cipher) newGCMStream(header []byte) (*gcmStream, error) {
+	if _, err := c.gcm(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	key, err := HKDF(c.key, header[:gcmStreamSaltSize], []byte("gcm-stream"), len(c.key))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &gcmStream{aead: aead, header: header, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+// next 下一块的 nonce
+func (s *gcmStream) next(last bool) ([]byte, error) {
+	if s.seq == ^uint32(0) {
+		return nil, errors.New("数据太大: 超出最大块数")
+	}
+	copy(s.nonce, s.header[gcmStreamSaltSize:gcmStreamSaltSize+gcmStreamPrefixSize])
+	binary.BigEndian.PutUint32(s.nonce[gcmStreamPrefixSize:], s.seq)
+	s.nonce[len(s.nonce)-1] = Ternary[byte](last, 1, 0)
+	s.seq++
+	return s.nonce, nil
+}
+
+// seal 加密一块数据
+func (s *gcmStream) seal(dst, chunk []byte, last bool) ([]byte, error) {
+	nonce, err := s.next(last)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return s.aead.Seal(dst, nonce, chunk, s.header), nil
+}
+
+// open 解密一块数据
+func (s *gcmStream) open(dst, chunk []byte, last bool) ([]byte, error) {
+	nonce, err := s.next(last)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	plain, err := s.aead.Open(dst, nonce, chunk, s.header)
+	if err != nil {
+		return nil, errors.Wrapf(err, "第%d块解密失败", s.seq)
+	}
+	return plain, nil
+}
+
+// encryptGCMStream GCM分块认证加密
+func (c *Cipher) encryptGCMStream(dst io.Writer, src io.Reader) error {
+	// 头部: 随机盐值 | 随机 nonce 前缀 | 块大小
+	header := make([]byte, gcmStreamHeaderSize)
+	if _, err := io.ReadFull(rand.Reader, header[:gcmStreamSaltSize+gcmStreamPrefixSize]); err != nil {
+		return errors.Wrap(err)
+	}
+	binary.BigEndian.PutUint32(header[gcmStreamSaltSize+gcmStreamPrefixSize:], gcmStreamChunkSize)
+
+	s, err := c.newGCMStream(header)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if _, err = dst.Write(header); err != nil {
+		return errors.Wrap(err)
+	}
+
+	var (
+		buf = make([]byte, 0, 2*gcmStreamChunkSize)
+		out = make([]byte, 0, gcmStreamChunkSize+s.aead.Overhead())
+	)
+	write := func(chunk []byte, last bool) error {
+		encrypt, err := s.seal(out[:0], chunk, last)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		_, err = dst.Write(encrypt)
+		return errors.Wrap(err)
+	}
+
+	// 保留最后一块, 读取结束后标记为最后一块加密
+	err = Read(src, func(_ int, block []byte) error {
+		buf = append(buf, block...)
+		for len(buf) > gcmStreamChunkSize {
+			if err := write(buf[:gcmStreamChunkSize], false); err != nil {
+				return err
+			}
+			buf = append(buf[:0], buf[gcmStreamChunkSize:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return write(buf, true)
+}
+
+// decryptGCMStream GCM分块认证解密
+func (c *Cipher) decryptGCMStream(dst io.Writer, src io.Reader) error {
+	header := make([]byte, gcmStreamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return errors.Wrap(err, "密文太短")
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[gcmStreamSaltSize+gcmStreamPrefixSize:]))
+	if chunkSize <= 0 || chunkSize > 16*int(MB) {
+		return errors.Errorf("块大小错误: %d", chunkSize)
+	}
+
+	s, err := c.newGCMStream(header)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	var (
+		size = chunkSize + s.aead.Overhead()
+		buf  = make([]byte, 0, 2*size)
+		out  = make([]byte, 0, chunkSize)
+	)
+	write := func(chunk []byte, last bool) error {
+		plain, err := s.open(out[:0], chunk, last)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		_, err = dst.Write(plain)
+		return errors.Wrap(err)
+	}
+
+	// 保留最后一块, 读取结束后按最后一块解密, 防止密文被截断
+	err = Read(src, func(_ int, block []byte) error {
+		buf = append(buf, block...)
+		for len(buf) > size {
+			if err := write(buf[:size], false); err != nil {
+				return err
+			}
+			buf = append(buf[:0], buf[size:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return write(buf, true)
+}
+
+// EncryptFile 加密文件: 分块读取 src 文件加密后写入 dst 文件
+//
+//	mode 加密模式: CTR, CFB, OFB, GCM
+//	opts dst 文件配置项, 默认覆盖已存在的文件; 加密失败时删除 dst 文件
+func (c *Cipher) EncryptFile(src, dst string, mode McryptMode, opts ...WriteOption) error {
+	return errors.Wrap(c.cryptFile(src, dst, opts, func(w io.Writer, r io.Reader) error {
+		return c.EncryptStream(w, r, mode)
+	}))
+}
+
+// DecryptFile 解密文件: 分块读取 EncryptFile 加密的 src 文件解密后写入 dst 文件
+//
+//	mode 加密模式: CTR, CFB, OFB, GCM
+//	opts dst 文件配置项, 默认覆盖已存在的文件; 解密失败时删除 dst 文件
+func (c *Cipher) DecryptFile(src, dst string, mode McryptMode, opts ...WriteOption) error {
+	return errors.Wrap(c.cryptFile(src, dst, opts, func(w io.Writer, r io.Reader) error {
+		return c.DecryptStream(w, r, mode)
+	}))
+}
+
+// cryptFile 读取 src 文件使用 crypt 处理后写入 dst 文件
+func (c *Cipher) cryptFile(src, dst string, opts []WriteOption, crypt func(w io.Writer, r io.Reader) error) (err error) {
+	s, _ := filepath.Abs(src)
+	d, _ := filepath.Abs(dst)
+	if s == d {
+		return errors.New("源文件与目标文件不能相同")
+	}
+
+	r, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer r.Close()
+
+	w, err := NewWrite(dst, opts...)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil && cerr != nil {
+			err = errors.Wrap(cerr)
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	return errors.Wrap(crypt(w, r))
+}
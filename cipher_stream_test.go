@@ -0,0 +1,171 @@
+package utils_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCipherStream(t *testing.T) {
+	data := make([]byte, 200*1024+7)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		opts []utils.CipherOption
+		mode utils.McryptMode
+		size int
+	}{
+		{name: "001", key: "1234567812345678", opts: []utils.CipherOption{utils.WithRandIV(true)}, mode: utils.CTR, size: len(data)},
+		{name: "002", key: "1234567812345678", opts: []utils.CipherOption{utils.WithRandIV(true)}, mode: utils.CFB, size: 100},
+		{name: "003", key: "1234567812345678", mode: utils.OFB, size: 33},
+		{name: "004", key: "12345678", mode: utils.CTR, size: 1000},
+		{name: "005", key: "1234567812345678", mode: utils.GCM, size: len(data)},
+		{name: "006", key: "9F9CE8D28048399BA52A2E40", mode: utils.GCM, size: 64 * 1024},
+		{name: "007", key: "884100890d03e9f1efeda1b393ecba1b", mode: utils.GCM, size: 0},
+		{name: "008", key: "1234567812345678", mode: utils.GCM, size: 128 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				c   *utils.Cipher
+				err error
+			)
+			if len(tt.key) == 8 {
+				c, err = utils.DES(tt.key, tt.opts...)
+			} else {
+				c, err = utils.AES(tt.key, tt.opts...)
+			}
+			if err != nil {
+				t.Fatalf("NewCipher() error = %v", err)
+			}
+
+			var encrypt bytes.Buffer
+			if err = c.EncryptStream(&encrypt, bytes.NewReader(data[:tt.size]), tt.mode); err != nil {
+				t.Fatalf("EncryptStream() error = %v", err)
+			}
+
+			var got bytes.Buffer
+			if err = c.DecryptStream(&got, bytes.NewReader(encrypt.Bytes()), tt.mode); err != nil {
+				t.Fatalf("DecryptStream() error = %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), data[:tt.size]) {
+				t.Errorf("解密后数据不等于加密前数据 len = %v, want %v", got.Len(), tt.size)
+			}
+		})
+	}
+
+	t.Run("GCM", func(t *testing.T) {
+		c, _ := utils.AES("1234567812345678")
+		var encrypt bytes.Buffer
+		if err := c.EncryptStream(&encrypt, bytes.NewReader(data), utils.GCM); err != nil {
+			t.Fatalf("EncryptStream() error = %v", err)
+		}
+		chunk := 64*1024 + 16
+		header := 32 + 7 + 4 // 盐值 | nonce前缀 | 块大小
+
+		cases := []struct {
+			name string
+			data func(b []byte) []byte
+		}{
+			// 篡改密文
+			{name: "tamper", data: func(b []byte) []byte { b[100] ^= 0x01; return b }},
+			// 截断最后一块
+			{name: "truncate", data: func(b []byte) []byte { return b[:header+2*chunk] }},
+			// 调换块顺序
+			{name: "reorder", data: func(b []byte) []byte {
+				first := bytes.Clone(b[header : header+chunk])
+				copy(b[header:], b[header+chunk:header+2*chunk])
+				copy(b[header+chunk:], first)
+				return b
+			}},
+			// 篡改盐值: 派生的秘钥不同
+			{name: "salt", data: func(b []byte) []byte { b[0] ^= 0x01; return b }},
+			// 密文太短
+			{name: "short", data: func(b []byte) []byte { return b[:5] }},
+		}
+		for _, tc := range cases {
+			var got bytes.Buffer
+			if err := c.DecryptStream(&got, bytes.NewReader(tc.data(bytes.Clone(encrypt.Bytes()))), utils.GCM); err == nil {
+				t.Errorf("DecryptStream() %s error = nil", tc.name)
+			}
+		}
+
+		// 每个流使用不同的盐值
+		var again bytes.Buffer
+		_ = c.EncryptStream(&again, bytes.NewReader(data[:10]), utils.GCM)
+		if bytes.Equal(again.Bytes()[:32], encrypt.Bytes()[:32]) {
+			t.Errorf("EncryptStream() 盐值重复")
+		}
+
+		// 其它秘钥解密
+		other, _ := utils.AES("8765432187654321")
+		if err := other.DecryptStream(new(bytes.Buffer), bytes.NewReader(encrypt.Bytes()), utils.GCM); err == nil {
+			t.Errorf("DecryptStream() 其它秘钥 error = nil")
+		}
+	})
+
+	t.Run("Mode", func(t *testing.T) {
+		c, _ := utils.AES("1234567812345678")
+		if err := c.EncryptStream(new(bytes.Buffer), bytes.NewReader(data), utils.CBC); err == nil {
+			t.Errorf("EncryptStream() CBC error = nil")
+		}
+		d, _ := utils.DES("12345678")
+		if err := d.EncryptStream(new(bytes.Buffer), bytes.NewReader(data), utils.GCM); err == nil {
+			t.Errorf("EncryptStream() DES GCM error = nil")
+		}
+	})
+}
+
+func TestCipherFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.bin")
+	data := make([]byte, 300*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := utils.AES("1234567812345678", utils.WithRandIV(true))
+	for _, mode := range []utils.McryptMode{utils.CTR, utils.GCM} {
+		encrypt := filepath.Join(dir, "data.enc")
+		if err := c.EncryptFile(src, encrypt, mode); err != nil {
+			t.Fatalf("EncryptFile() mode = %v error = %v", mode, err)
+		}
+
+		decrypt := filepath.Join(dir, "data.dec")
+		if err := c.DecryptFile(encrypt, decrypt, mode); err != nil {
+			t.Fatalf("DecryptFile() mode = %v error = %v", mode, err)
+		}
+		got, err := os.ReadFile(decrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("解密后数据不等于加密前数据 mode = %v", mode)
+		}
+	}
+
+	// 解密失败时删除目标文件
+	bad := filepath.Join(dir, "bad.dec")
+	if err := c.DecryptFile(src, bad, utils.GCM); err == nil {
+		t.Errorf("DecryptFile() error = nil")
+	}
+	if utils.IsExist(bad) {
+		t.Errorf("DecryptFile() 失败后目标文件未删除")
+	}
+
+	// 源文件与目标文件相同
+	if err := c.EncryptFile(src, src, utils.CTR); err == nil {
+		t.Errorf("EncryptFile() 源文件与目标文件相同 error = nil")
+	}
+}
@@ -61,3 +61,76 @@ func TestCipher(t *testing.T) {
 		})
 	}
 }
+
+func TestCipherGCM(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		opts    []utils.CipherOption
+		data    string
+		aad     string
+		openAAD string
+		tamper  bool
+		wantErr bool
+	}{
+		{name: "001", key: "1234567812345678", data: "123456"},
+		{name: "002", key: "9F9CE8D28048399BA52A2E40", data: "123456", aad: "user:1", openAAD: "user:1"},
+		{name: "003", key: "884100890d03e9f1efeda1b393ecba1b", opts: []utils.CipherOption{utils.WithIV("8048399BA52A2E40")}, data: ""},
+		{name: "004", key: "1234567812345678", data: "123456", aad: "user:1", openAAD: "user:2", wantErr: true},
+		{name: "005", key: "1234567812345678", data: "123456", tamper: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := utils.AES(tt.key, tt.opts...)
+			if err != nil {
+				t.Fatalf("AES() error = %v", err)
+			}
+
+			encrypt, err := a.EncryptGCM([]byte(tt.data), []byte(tt.aad))
+			if err != nil {
+				t.Fatalf("EncryptGCM() error = %v", err)
+			}
+
+			// 随机 nonce: 相同明文密文不同
+			again, _ := a.EncryptGCM([]byte(tt.data), []byte(tt.aad))
+			if reflect.DeepEqual(encrypt, again) {
+				t.Errorf("EncryptGCM() 两次加密密文相同")
+			}
+
+			if tt.tamper {
+				encrypt[len(encrypt)-1] ^= 0x01
+			}
+			got, err := a.DecryptGCM(encrypt, []byte(tt.openAAD))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptGCM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.data {
+				t.Errorf("解密后数据不等于加密前数据 got = %v, want %v", string(got), tt.data)
+			}
+		})
+	}
+
+	t.Run("Encrypt", func(t *testing.T) {
+		a, _ := utils.AES("1234567812345678", utils.WithRandIV(true))
+		encryptStr, err := a.Encrypt("123456", utils.GCM, base64.StdEncoding.EncodeToString, nil)
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		got, err := a.Decrypt(encryptStr, utils.GCM, base64.StdEncoding.DecodeString, nil)
+		if err != nil || got != "123456" {
+			t.Errorf("Decrypt() = %v, %v, want 123456", got, err)
+		}
+
+		// 密文太短
+		if _, err := a.Decrypt(base64.StdEncoding.EncodeToString([]byte("short")), utils.GCM, base64.StdEncoding.DecodeString, nil); err == nil {
+			t.Errorf("Decrypt() 密文太短 error = nil")
+		}
+	})
+
+	t.Run("DES", func(t *testing.T) {
+		d, _ := utils.DES("12345678")
+		if _, err := d.EncryptGCM([]byte("123456"), nil); err == nil {
+			t.Errorf("EncryptGCM() DES error = nil")
+		}
+	})
+}
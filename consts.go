@@ -7,6 +7,7 @@ const (
 	CTR                   // 2 计算器模式（Counter，CTR）
 	CFB                   // 3 密码反馈模式（Cipher FeedBack，CFB）
 	OFB                   // 4 输出反馈模式（Output FeedBack，OFB）
+	GCM                   // 5 伽罗瓦/计数器模式（Galois/Counter Mode，GCM），认证加密，仅支持AES，无须填充
 )
 
 // 计算机存储单位：Byte、KB、MB、GB、TB、PB、EB
@@ -10,6 +10,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
+	"net/textproto"
 	"net/url"
 	"os"
 	"strings"
@@ -70,9 +71,45 @@ type Curl struct {
 	// 请求标识
 	requestId string
 
-	// 失败重连次数: 默认2次，最大5次
+	// 失败重连次数: 默认2次，最大5次; 设置了重试策略时不限制最大次数
 	maxRetry uint8
 
+	// 重试策略: 未设置时仅在传输错误时重试
+	retryPolicy RetryPolicy
+
+	// 按 Host 限制请求频率和并发数
+	limiter *HostLimiter
+
+	// 按 Host 熔断
+	breaker *CircuitBreaker
+
+	// 中间件, 先添加的在外层
+	middlewares []Middleware
+
+	// 自定义 Client.Transport
+	transport http.RoundTripper
+
+	// 共享 Transport 的连接池: nil 使用 DefaultTransportPool
+	pool *TransportPool
+
+	// 从连接池获取的 Transport
+	pooled http.RoundTripper
+
+	// 会话模式: 保存响应中的 Cookie 并在后续请求中发送
+	jar http.CookieJar
+
+	// 请求统计回调方法
+	observer func(stats RequestStats)
+
+	// 日志脱敏
+	redactor *Redactor
+
+	// 请求体压缩方式: gzip, deflate
+	compression string
+
+	// 访问令牌来源: 设置请求头 Authorization
+	tokenSource TokenSource
+
 	// dump 模式：使用httputil包下的 DumpRequestOut, DumpResponse 记录请求和响应的详细信息
 	dump bool
 
@@ -213,12 +250,79 @@ func WithCurlStatusCode(statusCode ...int) CurlOption {
 }
 
 // WithCurlMaxRetry 设置失败重试次数
+//
+//	请求体不可重复读取且不可随机读取(如 io.Pipe)时最多缓存 1MB 用于重试, 超出时不重试
 func WithCurlMaxRetry(max uint8) CurlOption {
 	return func(c *Curl) {
 		c.SetMaxRetry(max)
 	}
 }
 
+// WithCurlRetryPolicy 设置重试策略
+func WithCurlRetryPolicy(policy RetryPolicy) CurlOption {
+	return func(c *Curl) {
+		c.SetRetryPolicy(policy)
+	}
+}
+
+// WithCurlRateLimit 设置每个 Host 每秒请求数及令牌桶容量(突发请求数)
+func WithCurlRateLimit(rps float64, burst int) CurlOption {
+	return func(c *Curl) {
+		c.SetRateLimit(rps, burst)
+	}
+}
+
+// WithCurlMaxConcurrent 设置每个 Host 最大并发请求数
+func WithCurlMaxConcurrent(n int) CurlOption {
+	return func(c *Curl) {
+		c.SetMaxConcurrent(n)
+	}
+}
+
+// WithCurlLimiter 设置限流器, 多个 Curl 实例使用同一个 HostLimiter 时共享限制
+func WithCurlLimiter(limiter *HostLimiter) CurlOption {
+	return func(c *Curl) {
+		c.SetLimiter(limiter)
+	}
+}
+
+// WithCurlCircuitBreaker 设置熔断器, 多个 Curl 实例使用同一个 CircuitBreaker 时共享熔断状态
+func WithCurlCircuitBreaker(breaker *CircuitBreaker) CurlOption {
+	return func(c *Curl) {
+		c.SetCircuitBreaker(breaker)
+	}
+}
+
+// WithCurlCookieJar 设置 CookieJar, 开启会话模式
+func WithCurlCookieJar(jar http.CookieJar) CurlOption {
+	return func(c *Curl) {
+		c.SetCookieJar(jar)
+	}
+}
+
+// WithCurlTransportPool 设置共享 Transport 的连接池
+//
+//	配置(代理、证书等)相同的 Curl 共享同一个 Transport, 不要在 BeforeClient 中修改共享的 Transport
+func WithCurlTransportPool(pool *TransportPool) CurlOption {
+	return func(c *Curl) {
+		c.SetTransportPool(pool)
+	}
+}
+
+// WithCurlTransport 设置 Client.Transport, 如测试时使用 curltest.Mock
+func WithCurlTransport(transport http.RoundTripper) CurlOption {
+	return func(c *Curl) {
+		c.SetTransport(transport)
+	}
+}
+
+// WithCurlRedactor 设置日志脱敏规则
+func WithCurlRedactor(redactor *Redactor) CurlOption {
+	return func(c *Curl) {
+		c.SetRedactor(redactor)
+	}
+}
+
 // WithCurlDump 设置是否开启 dump 模式
 func WithCurlDump(dump bool) CurlOption {
 	return func(c *Curl) {
@@ -258,7 +362,8 @@ func NewCurl(opts ...CurlOption) *Curl {
 		requestId:          "",
 		maxRetry:           2,
 		dump:               false,
-		dumpBodyLimit:      4096,
+		redactor:           DefaultRedactor(),
+		dumpBodyLimit:      defaultBodyLimit,
 		defLogOutput:       false,
 		Logger:             Log(),
 	}
@@ -560,25 +665,131 @@ func (c *Curl) SetMaxRetry(max uint8) *Curl {
 	return c
 }
 
+// SetRetryPolicy 设置重试策略, 设置为nil则恢复默认策略(仅在传输错误时重试)
+//
+//	内置策略: NewExponentialRetry, NewConstantRetry
+//	设置了重试策略时, 最大请求次数由 SetMaxRetry 决定, 不再限制最大5次
+func (c *Curl) SetRetryPolicy(policy RetryPolicy) *Curl {
+	c.retryPolicy = policy
+	return c
+}
+
+// SetRateLimit 设置每个 Host 每秒请求数及令牌桶容量(突发请求数), 未设置限流器时创建一个新的限流器
+//
+//	限流器由多个 Curl 共享时, 修改对所有 Curl 生效
+func (c *Curl) SetRateLimit(rps float64, burst int) *Curl {
+	if c.limiter == nil {
+		c.limiter = NewHostLimiter(rps, burst, 0)
+		return c
+	}
+	c.limiter.SetRate(rps, burst)
+	return c
+}
+
+// SetMaxConcurrent 设置每个 Host 最大并发请求数, 未设置限流器时创建一个新的限流器
+//
+//	限流器由多个 Curl 共享时, 修改对所有 Curl 生效
+func (c *Curl) SetMaxConcurrent(n int) *Curl {
+	if c.limiter == nil {
+		c.limiter = NewHostLimiter(0, 0, n)
+		return c
+	}
+	c.limiter.SetMaxConcurrent(n)
+	return c
+}
+
+// SetLimiter 设置限流器, 多个 Curl 实例使用同一个 HostLimiter 时共享限制; 设置为nil取消限制
+func (c *Curl) SetLimiter(limiter *HostLimiter) *Curl {
+	c.limiter = limiter
+	return c
+}
+
+// GetLimiter 获取限流器
+func (c *Curl) GetLimiter() *HostLimiter {
+	return c.limiter
+}
+
+// SetCookieJar 设置 CookieJar, 开启会话模式: 保存响应中的 Cookie 并在后续请求(含重定向)中发送; 设置为nil关闭会话模式
+//
+//	jar 可使用 NewCookieJar 或 LoadCookieJar 创建, 支持持久化到文件; SetCookies 设置的 Cookie 仍会在每次请求中发送
+func (c *Curl) SetCookieJar(jar http.CookieJar) *Curl {
+	c.jar = jar
+	return c
+}
+
+// GetCookieJar 获取 CookieJar
+func (c *Curl) GetCookieJar() http.CookieJar {
+	return c.jar
+}
+
+// SetTransportPool 设置共享 Transport 的连接池, 设置为nil使用 DefaultTransportPool
+func (c *Curl) SetTransportPool(pool *TransportPool) *Curl {
+	c.pool = pool
+	return c
+}
+
+// GetTransportPool 获取共享 Transport 的连接池
+func (c *Curl) GetTransportPool() *TransportPool {
+	if c.pool == nil {
+		return defaultTransportPool
+	}
+	return c.pool
+}
+
+// SetTransport 设置 Client.Transport, 设置后代理、证书等 Transport 相关配置不再生效; 设置为nil使用默认 Transport
+func (c *Curl) SetTransport(transport http.RoundTripper) *Curl {
+	c.transport = transport
+	if c.cli != nil {
+		c.cli.Transport = transport
+	}
+	return c
+}
+
+// SetCircuitBreaker 设置熔断器, 熔断器打开时请求返回 ErrCircuitOpen; 设置为nil取消熔断
+func (c *Curl) SetCircuitBreaker(breaker *CircuitBreaker) *Curl {
+	c.breaker = breaker
+	return c
+}
+
+// GetCircuitBreaker 获取熔断器
+func (c *Curl) GetCircuitBreaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// SetRedactor 设置日志脱敏规则, 作用于默认日志、dump、HTTPError 及请求统计; 默认: DefaultRedactor(), 设置为nil不脱敏
+func (c *Curl) SetRedactor(redactor *Redactor) *Curl {
+	c.redactor = redactor
+	return c
+}
+
+// GetRedactor 获取日志脱敏规则
+func (c *Curl) GetRedactor() *Redactor {
+	return c.redactor
+}
+
 // SetDump dump模式会详细打印请求和响应的信息，否则只记录关键信息
 func (c *Curl) SetDump(dump bool) *Curl {
 	c.dump = dump
 	return c
 }
 
-// BeforeRequest 请求前处理 Request
+// BeforeRequest 请求前处理 Request, 重复设置会覆盖; 需组合多个处理方法请使用 Use
 func (c *Curl) BeforeRequest(f func(request *http.Request) error) *Curl {
 	c.beforeRequest = f
 	return c
 }
 
-// BeforeClient 请求前处理 Client
+// BeforeClient 请求前处理 Client, 重复设置会覆盖; 需组合多个处理方法请使用 Use
+//
+//	在中间件链的最内层执行, 每次 Send 执行一次(重试时不重复执行)
+//	注意: 未设置 WithCurlTransport 时 Client.Transport 是连接池(DefaultTransportPool)中多个 Curl 共享的 Transport,
+//	修改其字段会影响所有共享该 Transport 的 Curl; 需单独配置 Transport 时应替换 Client.Transport 或使用 WithCurlTransport
 func (c *Curl) BeforeClient(f func(request *http.Client) error) *Curl {
 	c.beforeClient = f
 	return c
 }
 
-// AfterResponse 请求后处理 Response
+// AfterResponse 请求后处理 Response, 重复设置会覆盖; 需组合多个处理方法请使用 Use
 //
 //	isDone 返回true 终止调用该方法之后的代码; 返回false 继续执行后续代码
 func (c *Curl) AfterResponse(f func(response *http.Response) (isDone bool, err error)) *Curl {
@@ -586,13 +797,16 @@ func (c *Curl) AfterResponse(f func(response *http.Response) (isDone bool, err e
 	return c
 }
 
-// AfterBody 请求后处理 Response.Body
+// AfterBody 请求后处理 Response.Body, 重复设置会覆盖; 需组合多个处理方法请使用 Use
 func (c *Curl) AfterBody(f func(body []byte) error) *Curl {
 	c.afterBody = f
 	return c
 }
 
-// AfterDone 请求完成后的处理方法, 如关闭连接等操作。 注意：client、request、response 有可能为nil
+// AfterDone 请求完成后的处理方法, 如关闭连接等操作, 重复设置会覆盖; 需组合多个处理方法请使用 Use
+//
+//	在中间件链的最外层执行, 在 AfterResponse, AfterBody 之后、关闭 Response.Body 之前; 请求失败时 response 为nil
+//	未进入中间件链(如创建请求失败、获取 Transport 失败)时不执行
 func (c *Curl) AfterDone(f func(client *http.Client, request *http.Request, response *http.Response)) *Curl {
 	c.afterDone = f
 	return c
@@ -603,14 +817,75 @@ func (c *Curl) AfterDone(f func(client *http.Client, request *http.Request, resp
 //	method 请求方式：GET, POST, PUT, DELETE, PATCH, HEAD
 //	url 请求地址
 //	body 请求体
-func (c *Curl) Send(method, url string, body io.Reader) (err error) {
+func (c *Curl) Send(method, url string, body io.Reader) error {
+	return c.SendContext(context.Background(), method, url, body)
+}
+
+// SendContext 携带 context 发起请求, ctx 取消或超时后终止请求、重试等待及响应体读取
+//
+//	ctx 上下文, 请求ID会写入ctx, 可通过 RequestIdFromContext 获取
+//	method 请求方式：GET, POST, PUT, DELETE, PATCH, HEAD
+//	url 请求地址
+//	body 请求体
+func (c *Curl) SendContext(ctx context.Context, method, url string, body io.Reader) error {
+	return c.send(ctx, method, url, body, sendHooks{})
+}
+
+// sendHooks 单次请求的附加处理方法, 在 Curl 设置的处理方法之后执行
+type sendHooks struct {
+	// 在 afterResponse 之后处理 Response, 如流式读取响应体
+	//	isDone 返回true 终止调用该方法之后的代码; 返回false 继续执行后续代码
+	response func(response *http.Response) (isDone bool, err error)
+
+	// 在 afterBody 之后处理 Response.Body
+	body func(response *http.Response, body []byte) error
+
+	// 除200及 Curl 已标记的状态码外, 本次请求可接受的状态码
+	statusCode []int
+
+	// 流式读取响应体: 非dump模式的日志不读取响应体
+	stream bool
+
+	// 长连接流(如 SSE): 不限制总超时时间, 日志不读取响应体
+	live bool
+
+	// 不限制总超时时间(如下载大文件), 由 ctx 及 headerTimer 控制
+	noTimeout bool
+
+	// 本次请求的请求头, 覆盖 Curl 设置的同名请求头, 不修改 Curl
+	header http.Header
+}
+
+// sendState 单次 Send 的状态
+type sendState struct {
+	start    time.Time     // 开始时间
+	attempts int           // 请求次数(含重试)
+	trace    *requestTrace // 请求各阶段耗时: 未设置 Observer 且未开启默认日志时为nil
+	entered  bool          // 是否已进入中间件链的最内层 do, 之后由 do 负责关闭请求体
+}
+
+// send 发起请求
+func (c *Curl) send(ctx context.Context, method, url string, body io.Reader, hooks sendHooks) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	t := time.Now()
 
+	// 请求统计
+	st := &sendState{start: t}
+	if c.observer != nil || c.defLogOutput {
+		st.trace = &requestTrace{}
+	}
+
 	// 设置 requestId
 	if c.requestId == "" {
 		c.SetRequestId()
 	}
 
+	// 请求ID写入ctx
+	ctx = WithRequestIdContext(ctx, c.requestId)
+
 	// Debug 日志
 	if c.defLogOutput {
 		c.Logger.Debug("HTTP START", "time", t.Format(time.RFC3339Nano))
@@ -639,23 +914,34 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 			}
 		}()
 
-		// 执行 done
-		if c.afterDone != nil {
-			// Debug 日志
-			if c.defLogOutput {
-				c.Logger.Debug("done()")
-			}
+		// 请求统计
+		if req != nil && st.trace != nil {
+			c.observe(req, resp, err, st)
+		}
 
-			c.afterDone(c.cli, req, resp)
+		// 未进入 do 时关闭请求体, 如 Form.Stream 写入数据的协程
+		if req != nil && !st.entered {
+			closeBody(req.Body)
 		}
 	}()
 
 	// 实例 Request
-	req, err = http.NewRequest(method, url, body)
+	req, err = http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
+		if rc, ok := body.(io.Closer); ok {
+			_ = rc.Close()
+		}
 		return errors.Wrap(err)
 	}
 
+	// 可重新生成的请求体, 如 Form.Stream
+	if gb, ok := body.(bodyGetter); ok && req.GetBody == nil {
+		req.GetBody = gb.GetBody
+		if l, ok := body.(interface{ Len() int }); ok && l.Len() > 0 {
+			req.ContentLength = int64(l.Len())
+		}
+	}
+
 	// 设置 header
 	if c.header != nil && len(c.header) > 0 {
 		// Debug 日志
@@ -663,7 +949,15 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 			c.Logger.Debug("set header")
 		}
 
-		req.Header = c.header
+		req.Header = c.header.Clone()
+	}
+
+	// 本次请求的请求头
+	for key, values := range hooks.header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
 	}
 
 	// 设置 Cookie
@@ -688,44 +982,6 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 		req.SetBasicAuth(c.username, c.password)
 	}
 
-	// 在发送请求之前对 Request处理方法
-	if c.beforeRequest != nil {
-		// Debug 日志
-		if c.defLogOutput {
-			c.Logger.Debug("request()")
-		}
-
-		if err = c.beforeRequest(req); err != nil {
-			return errors.Wrap(err)
-		}
-	}
-
-	// 记录请求日志
-	if c.defLogOutput && c.Logger.Enabled(context.Background(), LevelInfo) {
-		if c.dump {
-			dump, err := dumpRequestSafe(req, c.dumpBodyLimit)
-			if err != nil {
-				return errors.Wrap(err)
-			}
-			c.Logger.Info("httputil.DumpRequestOut()", "request", dump) // Info 日志
-		} else {
-			// 只记录关键性日志
-			var b strings.Builder
-			b.WriteString(method + ": " + url + "\n")
-
-			if req.Body != nil {
-				b.WriteString("Request Body:\n")
-				var reqBody []byte
-				reqBody, req.Body, err = DrainBody(req.Body)
-				if err != nil {
-					return errors.Wrap(err)
-				}
-				b.Write(reqBody)
-			}
-			c.Logger.Info("DrainBody(req.Body)", "body", b.String()) // Info 日志
-		}
-	}
-
 	// 如果Client未初始化进行初始化
 	if c.cli == nil {
 		// Debug 日志
@@ -736,95 +992,97 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 		c.cli = &http.Client{}
 	}
 
-	// 设置超时时间
-	c.cli.Timeout = Ternary(c.timeout > 0, c.timeout, 30*time.Second)
+	// 设置超时时间: 长连接流由 ctx 控制
+	c.cli.Timeout = Ternary(hooks.live || hooks.noTimeout, 0, Ternary(c.timeout > 0, c.timeout, 30*time.Second))
+
+	// 会话模式
+	c.cli.Jar = c.jar
+
+	// 使用自定义 Transport
+	if c.cli.Transport == nil && c.transport != nil {
+		c.cli.Transport = c.transport
+	}
 
-	// 初始化Transport
-	if c.cli.Transport == nil {
+	// 从连接池获取共享的 Transport: 代理、证书等配置变更后获取新的 Transport
+	if c.cli.Transport == nil || (c.cli.Transport == c.pooled && c.transport == nil) {
 		// Debug 日志
 		if c.defLogOutput {
 			c.Logger.Debug("Init Transport")
 		}
 
-		tr := &http.Transport{}
-
-		// 设置代理
-		if len(c.proxyURL) > 0 {
-			// Debug 日志
-			if c.defLogOutput {
-				c.Logger.Debug("ProxyURL()")
-			}
-
-			if err = ProxyURL(tr, c.proxyURL); err != nil {
-				return errors.Wrap(err)
-			}
+		tr, err := c.GetTransportPool().Transport(TransportKey{
+			ProxyURL:           c.proxyURL,
+			InsecureSkipVerify: c.insecureSkipVerify,
+			RootCAs:            c.rootCAs,
+			Cert:               c.cert,
+			Key:                c.key,
+		})
+		if err != nil {
+			return errors.Wrap(err)
 		}
+		c.cli.Transport, c.pooled = tr, tr
+	}
 
-		// 跳过https不安全验证
-		if c.insecureSkipVerify {
-			// Debug 日志
-			if c.defLogOutput {
-				c.Logger.Debug("InsecureSkipVerify")
-			}
-
-			if tr.TLSClientConfig == nil {
-				tr.TLSClientConfig = &tls.Config{}
-			}
-			tr.TLSClientConfig.InsecureSkipVerify = true
+	// 限制并发请求数, 直到读取完响应体后释放
+	if c.limiter != nil {
+		release, err := c.limiter.Acquire(ctx, req.URL.Host)
+		if err != nil {
+			c.Logger.Warn("limiter.Acquire()", "host", c.redactor.host(req.URL.Host), "err", c.redactor.err(err)) // Warn 日志
+			return errors.Wrap(err)
 		}
+		defer release()
+	}
 
-		// 根证书
-		if len(c.rootCAs) > 0 {
-			// Debug 日志
-			if c.defLogOutput {
-				c.Logger.Debug("RootCAs()")
-			}
-
-			if tr.TLSClientConfig == nil {
-				tr.TLSClientConfig = &tls.Config{}
-			}
+	// 经过中间件链发送请求
+	resp, err = c.handler(hooks, st).RoundTrip(req)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if resp == nil {
+		return errors.New("http: RoundTrip returned a nil *Response")
+	}
 
-			if err = RootCAs(tr.TLSClientConfig, c.rootCAs); err != nil {
-				return errors.Wrap(err)
-			}
-		}
+	// Debug 日志
+	if c.defLogOutput {
+		c.Logger.Debug("HTTP END", "total time spent", time.Since(t).String())
+	}
 
-		// 证书
-		if len(c.cert) > 0 && len(c.key) > 0 {
-			// Debug 日志
-			if c.defLogOutput {
-				c.Logger.Debug("Certificate()")
-			}
+	return nil
+}
 
-			if tr.TLSClientConfig == nil {
-				tr.TLSClientConfig = &tls.Config{}
-			}
+// do 中间件链的最内层: 发送请求, 处理限流、熔断及重试
+//
+//	请求体交给 Client.Do 后由 Transport 关闭, 未交给 Client.Do 的请求体(如限流、熔断返回错误)在返回前关闭
+func (c *Curl) do(req *http.Request, st *sendState) (resp *http.Response, err error) {
+	ctx := req.Context()
+	st.entered = true
 
-			if err = Certificate(tr.TLSClientConfig, c.cert, c.key); err != nil {
-				return errors.Wrap(err)
-			}
+	// 当前请求体是否已交给 Client.Do
+	sent := false
+	defer func() {
+		if !sent {
+			closeBody(req.Body)
 		}
+	}()
 
-		c.cli.Transport = tr
+	// 失败重连次数: 默认2次, 最大5次; 设置了重试策略时不限制最大次数
+	maxRetry := Ternary(c.maxRetry > 0, int(c.maxRetry), 2)
+	var policy RetryPolicy = defaultRetry{}
+	if c.retryPolicy != nil {
+		policy = c.retryPolicy
+	} else {
+		maxRetry = Ternary(maxRetry > 5, 5, maxRetry)
 	}
 
-	// 在发送请求之前对Client处理方法
-	if c.beforeClient != nil {
-		// Debug 日志
-		if c.defLogOutput {
-			c.Logger.Debug("client()")
-		}
-
-		err = c.beforeClient(c.cli)
+	// 重试前需重置请求体, 不可重复读取的请求体先缓存
+	if maxRetry > 1 {
+		release, err := rewindableBody(req)
 		if err != nil {
-			return errors.Wrap(err)
+			return nil, errors.Wrap(err)
 		}
+		defer release()
 	}
 
-	// 失败重连次数: 默认2次, 最大5次
-	maxRetry := Ternary(c.maxRetry > 0, int(c.maxRetry), 2)
-	maxRetry = Ternary(maxRetry > 5, 5, maxRetry)
-
 	t1 := time.Now()
 	// Debug 日志
 	if c.defLogOutput {
@@ -832,16 +1090,88 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 	}
 
 	// 发送请求
-	for i := 1; i <= maxRetry; i++ {
-		resp, err = c.cli.Do(req)
-		if err == nil {
-			// 请求成功后终止
+	attempt := 0
+	for {
+		attempt++
+		st.attempts = attempt
+
+		// 限制请求频率
+		if c.limiter != nil {
+			wait, err := c.limiter.Wait(ctx, req.URL.Host)
+			if err != nil {
+				c.Logger.Warn("limiter.Wait()", "host", c.redactor.host(req.URL.Host), "err", c.redactor.err(err)) // Warn 日志
+				return nil, errors.Wrap(err)
+			}
+			if wait > 0 {
+				c.Logger.Warn("limiter.Wait()", "host", c.redactor.host(req.URL.Host), "wait", wait.String()) // Warn 日志
+			}
+		}
+
+		// 熔断
+		var report func(resp *http.Response, err error)
+		if c.breaker != nil {
+			if report, err = c.breaker.AllowContext(ctx, req.URL.Host); err != nil {
+				c.Logger.Warn("breaker.Allow()", "host", c.redactor.host(req.URL.Host), "currentRetry", attempt, "err", c.redactor.err(err)) // Warn 日志
+				return nil, errors.Wrap(err)
+			}
+		}
+
+		// 统计请求各阶段耗时
+		treq := req
+		if st.trace != nil {
+			treq = req.WithContext(st.trace.context(ctx))
+		}
+
+		sent = true
+		resp, err = c.cli.Do(treq)
+		if report != nil {
+			report(resp, err)
+		}
+
+		// ctx 已取消或超时, 不再重试
+		if ctx.Err() != nil || attempt >= maxRetry {
+			break
+		}
+
+		wait, retry := policy.Retry(attempt, req, resp, err)
+		if !retry {
 			break
 		}
 
-		if i < maxRetry {
-			c.Logger.Warn("client.Do()", "maxRetry", maxRetry, "currentRetry", i, "err", err.Error()) // Warn 日志
-			time.Sleep(time.Millisecond * time.Duration(2<<(2*i)))                                    // 间隔 8, 32, 128, 512 毫秒
+		// 重置请求体, 无法重置则不再重试
+		var reqBody io.ReadCloser
+		if req.GetBody != nil {
+			var bodyErr error
+			if reqBody, bodyErr = req.GetBody(); bodyErr != nil {
+				c.Logger.Warn("req.GetBody()", "currentRetry", attempt, "err", c.redactor.err(bodyErr)) // Warn 日志
+				break
+			}
+		} else if req.Body != nil && req.Body != http.NoBody {
+			c.Logger.Warn("req.GetBody()", "currentRetry", attempt, "err", "请求体不支持重复读取") // Warn 日志
+			break
+		}
+
+		if err != nil {
+			c.Logger.Warn("client.Do()", "maxRetry", maxRetry, "currentRetry", attempt, "wait", wait.String(), "err", c.redactor.err(err)) // Warn 日志
+		} else {
+			c.Logger.Warn("client.Do()", "maxRetry", maxRetry, "currentRetry", attempt, "wait", wait.String(), "statusCode", resp.StatusCode) // Warn 日志
+
+			// 丢弃本次响应
+			discardBody(resp)
+			resp = nil
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			if reqBody != nil {
+				_ = reqBody.Close()
+			}
+			return nil, errors.Wrap(err)
+		}
+
+		if reqBody != nil {
+			// 关闭上次的请求体(如压缩请求体), Transport 提前返回响应时可能仍未关闭
+			closeBody(req.Body)
+			req.Body, sent = reqBody, false
 		}
 	}
 
@@ -851,39 +1181,18 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 	}
 
 	if err != nil {
-		return errors.Errorf("client.Do() Retry %d times err: %v", maxRetry, err.Error())
+		return nil, errors.Wrapf(err, "client.Do() Retry %d times", attempt)
 	}
+	return resp, nil
+}
 
-	// 返回body内容
-	var respBody []byte
-
-	// 记录返回日志
-	if c.defLogOutput && c.Logger.Enabled(context.Background(), LevelInfo) {
-		if c.dump {
-			dump, err := dumpResponseSafe(resp, c.dumpBodyLimit)
-			if err != nil {
-				return errors.Wrap(err)
-			}
-			c.Logger.Info("httputil.DumpResponse()", "response", dump) // Info 日志
-		} else {
-			// 只记录返回的关键信息
-			var b strings.Builder
-			b.WriteString(fmt.Sprintf("Response Status: %s\n", resp.Status))
-			b.WriteString("Response Body:\n")
-
-			// 读取body内容
-			respBody, resp.Body, err = DrainBody(resp.Body)
-			if err != nil {
-				return errors.Wrap(err)
-			}
-			b.Write(respBody)
-			c.Logger.Info("DrainBody(resp.Body)", "Body", b.String()) // Info 日志
-		}
-	}
+// resolve 校验状态码并执行 AfterResponse, AfterBody 及单次请求附加处理方法
+func (c *Curl) resolve(req *http.Request, resp *http.Response, hooks sendHooks, st *sendState) (err error) {
+	ctx := req.Context()
 
 	// 判断状态码是否是200正常状态及已标记的状态码
-	if resp.StatusCode != 200 && !IsHas(resp.StatusCode, c.statusCode) {
-		return errors.Errorf("response error StatusCode: statusCode=%d, Status=%s", resp.StatusCode, resp.Status)
+	if resp.StatusCode != 200 && !IsHas(resp.StatusCode, c.statusCode) && !IsHas(resp.StatusCode, hooks.statusCode) {
+		return errors.Wrap(newHTTPError(req, resp, st, c.dumpBodyLimit, c.redactor))
 	}
 
 	// 在发送请求之后可以对Response处理方法
@@ -904,38 +1213,53 @@ func (c *Curl) Send(method, url string, body io.Reader) (err error) {
 		}
 	}
 
+	// 单次请求的 Response 处理方法
+	if hooks.response != nil {
+		isDone, err := hooks.response(resp)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if isDone {
+			return nil
+		}
+	}
+
+	// ctx 已取消或超时
+	if err = ctx.Err(); err != nil {
+		return errors.Wrap(err)
+	}
+
 	// 在发送请求之后对Response.Body处理方法
-	if c.afterBody != nil {
+	if c.afterBody != nil || hooks.body != nil {
 		// Debug 日志
 		if c.defLogOutput {
 			c.Logger.Debug("resolve()")
 		}
 
-		if respBody == nil {
-			// 读取body内容
-			var buf bytes.Buffer
-			//respBody, err := io.ReadAll(resp.Body)
-			_, err = buf.ReadFrom(resp.Body)
-			if err != nil {
-				return errors.Wrap(err)
-			}
-			respBody = buf.Bytes()
+		// 读取body内容
+		var buf bytes.Buffer
+		if _, err = buf.ReadFrom(ctxReadCloser(ctx, resp.Body)); err != nil {
+			return errors.Wrap(err)
 		}
+		respBody := buf.Bytes()
 
-		if err = c.afterBody(respBody); err != nil {
-			return errors.Wrap(err)
+		if c.afterBody != nil {
+			if err = c.afterBody(respBody); err != nil {
+				return errors.Wrap(fillDecodeError(err, resp))
+			}
 		}
-	}
 
-	// Debug 日志
-	if c.defLogOutput {
-		c.Logger.Debug("HTTP END", "total time spent", time.Since(t).String())
+		if hooks.body != nil {
+			if err = hooks.body(resp, respBody); err != nil {
+				return errors.Wrap(err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// CloseIdleConnections 关闭连接
+// CloseIdleConnections 关闭连接, 使用连接池共享的 Transport 时会同时关闭其它 Curl 的空闲连接
 func (c *Curl) CloseIdleConnections() {
 	if c.cli != nil {
 		c.cli.CloseIdleConnections()
@@ -944,68 +1268,255 @@ func (c *Curl) CloseIdleConnections() {
 }
 
 // Get 请求方式
-func (c *Curl) Get(url string) (err error) {
+func (c *Curl) Get(url string) error {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext 携带 context 的 Get 请求方式
+func (c *Curl) GetContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodGet, url, c.body)
+	return c.SendContext(ctx, http.MethodGet, url, c.body)
 }
 
 // Post 请求方式
-func (c *Curl) Post(url string) (err error) {
+func (c *Curl) Post(url string) error {
+	return c.PostContext(context.Background(), url)
+}
+
+// PostContext 携带 context 的 Post 请求方式
+func (c *Curl) PostContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodPost, url, c.body)
+	return c.SendContext(ctx, http.MethodPost, url, c.body)
 }
 
 // PostForm 请求方式
 func (c *Curl) PostForm(url string) error {
+	return c.PostFormContext(context.Background(), url)
+}
+
+// PostFormContext 携带 context 的 PostForm 请求方式
+func (c *Curl) PostFormContext(ctx context.Context, url string) error {
 	return c.SetContentType("application/x-www-form-urlencoded").
-		Send(http.MethodPost, url, strings.NewReader(c.params.Encode()))
+		SendContext(ctx, http.MethodPost, url, strings.NewReader(c.params.Encode()))
 }
 
 // Put 请求方式
-func (c *Curl) Put(url string) (err error) {
+func (c *Curl) Put(url string) error {
+	return c.PutContext(context.Background(), url)
+}
+
+// PutContext 携带 context 的 Put 请求方式
+func (c *Curl) PutContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodPut, url, c.body)
+	return c.SendContext(ctx, http.MethodPut, url, c.body)
 }
 
 // Patch 请求方式
-func (c *Curl) Patch(url string) (err error) {
+func (c *Curl) Patch(url string) error {
+	return c.PatchContext(context.Background(), url)
+}
+
+// PatchContext 携带 context 的 Patch 请求方式
+func (c *Curl) PatchContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodPatch, url, c.body)
+	return c.SendContext(ctx, http.MethodPatch, url, c.body)
 }
 
 // Head 请求方式
 func (c *Curl) Head(url string) error {
-	return c.Send(http.MethodHead, url, nil)
+	return c.HeadContext(context.Background(), url)
+}
+
+// HeadContext 携带 context 的 Head 请求方式
+func (c *Curl) HeadContext(ctx context.Context, url string) error {
+	return c.SendContext(ctx, http.MethodHead, url, nil)
 }
 
 // Delete 请求方式
-func (c *Curl) Delete(url string) (err error) {
+func (c *Curl) Delete(url string) error {
+	return c.DeleteContext(context.Background(), url)
+}
+
+// DeleteContext 携带 context 的 Delete 请求方式
+func (c *Curl) DeleteContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodDelete, url, c.body)
+	return c.SendContext(ctx, http.MethodDelete, url, c.body)
 }
 
 // Options 请求方式
-func (c *Curl) Options(url string) (err error) {
+func (c *Curl) Options(url string) error {
+	return c.OptionsContext(context.Background(), url)
+}
+
+// OptionsContext 携带 context 的 Options 请求方式
+func (c *Curl) OptionsContext(ctx context.Context, url string) (err error) {
 	url, err = UrlPath(url, c.params)
 	if err != nil {
 		return errors.Wrap(err)
 	}
-	return c.Send(http.MethodOptions, url, c.body)
+	return c.SendContext(ctx, http.MethodOptions, url, c.body)
+}
+
+// bodyGetter 可重新生成的请求体, 用于重试时重置请求体及日志预览
+//
+//	GetBody 需返回独立读取的新请求体, 不能影响正在发送的请求体
+type bodyGetter interface {
+	GetBody() (io.ReadCloser, error)
+}
+
+// retryBodyLimit 重试时缓存不可重复读取的请求体的最大长度, 超出时不重试
+const retryBodyLimit = 1 << 20
+
+// rewindableBody 请求体不可重复读取(GetBody为nil)时设置GetBody, 用于重试时重置请求体
+//
+//	实现 io.ReaderAt 及 io.Seeker 的请求体(如 *os.File)每次从当前位置独立读取, 不缓存到内存
+//	其它请求体最多缓存 retryBodyLimit 字节, 超出时不设置GetBody(不重试)
+//	release 请求完成后关闭原请求体
+func rewindableBody(req *http.Request) (release func(), err error) {
+	release = func() {}
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return release, nil
+	}
+
+	// 可随机读取的请求体
+	if ra, ok := req.Body.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		if offset, end, ok := seekRange(ra); ok {
+			body := req.Body
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(ra, offset, end-offset)), nil
+			}
+			req.Body, _ = req.GetBody()
+			return func() { _ = body.Close() }, nil
+		}
+	}
+
+	// 缓存请求体, 超出上限时不重试
+	buf, err := io.ReadAll(io.LimitReader(req.Body, retryBodyLimit+1))
+	if err != nil {
+		_ = req.Body.Close()
+		return release, errors.Wrap(err)
+	}
+	if len(buf) > retryBodyLimit {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+		return release, nil
+	}
+
+	_ = req.Body.Close()
+	req.ContentLength = int64(len(buf))
+	req.GetBody = func() (io.ReadCloser, error) {
+		if len(buf) == 0 {
+			return http.NoBody, nil
+		}
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return release, nil
+}
+
+// seekRange 获取 s 的当前位置及结束位置, 不改变当前位置
+func seekRange(s io.Seeker) (offset, end int64, ok bool) {
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, false
+	}
+	if end, err = s.Seek(0, io.SeekEnd); err != nil {
+		return 0, 0, false
+	}
+	if _, err = s.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, false
+	}
+	return offset, end, true
+}
+
+// discardBody 丢弃并关闭响应体, 以便复用连接
+func discardBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+}
+
+// requestIdKey 请求ID在context中的键
+type requestIdKey struct{}
+
+// WithRequestIdContext 将请求ID写入context
+func WithRequestIdContext(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestIdFromContext 从context中获取请求ID, 未设置返回空字符串
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// sleepContext 休眠指定时间, ctx 取消或超时提前返回 ctx.Err()
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ctxBody 读取前检查 ctx 是否已取消或超时的 ReadCloser
+type ctxBody struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+// ctxReadCloser 包装 body, 读取时响应 ctx 取消
+func ctxReadCloser(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if body == nil || body == http.NoBody {
+		return body
+	}
+	return &ctxBody{ctx: ctx, ReadCloser: body}
+}
+
+// Read 实现 io.Reader 接口
+func (b *ctxBody) Read(p []byte) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// closeBody 关闭请求体, 忽略错误
+func closeBody(body io.ReadCloser) {
+	if body != nil && body != http.NoBody {
+		_ = body.Close()
+	}
 }
 
 // DrainBody 读取read内容并返回其内容和一个新的ReadCloser，
@@ -1070,6 +1581,24 @@ func ProxyURL(transport *http.Transport, proxyURL string) error {
 type Form struct {
 	Params url.Values
 	Files  url.Values
+
+	// 以 io.Reader 上传的数据块
+	parts []formPart
+
+	// 上传进度回调方法
+	progress UploadProgress
+}
+
+// UploadProgress 上传进度
+//   - written 已上传字节数
+//   - total 总字节数: 未知(如流式上传)时为 -1
+//   - rate 上传速率(字节/秒)
+type UploadProgress func(written, total int64, rate float64)
+
+// formPart 以 io.Reader 上传的数据块
+type formPart struct {
+	header textproto.MIMEHeader
+	reader io.Reader
 }
 
 // SetParam 设置Params键值对
@@ -1150,23 +1679,152 @@ func (f *Form) DelFiles(fileNames ...string) {
 	}
 }
 
+// AddFileReader 以 io.Reader 添加上传文件
+//
+//	fieldName 表单字段名
+//	fileName 文件名
+//	r 文件内容: 流式上传且需要重试或日志预览时 r 需实现 io.ReaderAt 及 io.Seeker, 如: *os.File, *bytes.Reader
+//	contentType 文件类型: 为空时默认 application/octet-stream
+func (f *Form) AddFileReader(fieldName, fileName string, r io.Reader, contentType string) *Form {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", multipartDisposition(fieldName, fileName))
+	header.Set("Content-Type", Ternary(contentType != "", contentType, "application/octet-stream"))
+	return f.AddPart(header, r)
+}
+
+// AddPart 以 io.Reader 添加自定义头信息的数据块
+//
+//	header 数据块头信息, 需包含 Content-Disposition, 如: form-data; name="field"; filename="a.txt"
+//	r 数据块内容: 流式上传且需要重试或日志预览时 r 需实现 io.ReaderAt 及 io.Seeker, 如: *os.File, *bytes.Reader
+func (f *Form) AddPart(header textproto.MIMEHeader, r io.Reader) *Form {
+	if r != nil {
+		f.parts = append(f.parts, formPart{header: header, reader: r})
+	}
+	return f
+}
+
+// OnProgress 设置上传进度回调方法, 进度按请求体被读取的字节数计算
+func (f *Form) OnProgress(progress UploadProgress) *Form {
+	f.progress = progress
+	return f
+}
+
+// isMultipart 是否需要以 multipart/form-data 上传
+func (f *Form) isMultipart() bool {
+	return len(f.Files) > 0 || len(f.parts) > 0
+}
+
 // Reader 读取Form内容，转换为以键值对上传文件和表单的body及content-type
+//
+//	内容会全部读取到内存中, 上传大文件请使用 Stream
 func (f *Form) Reader() (body io.Reader, contentType string, err error) {
-	if f.Files == nil || len(f.Files) == 0 {
-		return strings.NewReader(f.Params.Encode()), "application/x-www-form-urlencoded", nil
+	if !f.isMultipart() {
+		body = strings.NewReader(f.Params.Encode())
+		if f.progress != nil {
+			body = f.withProgress(body, nil)
+		}
+		return body, "application/x-www-form-urlencoded", nil
 	}
 
 	b := &bytes.Buffer{}
 
 	// 创建一个multipart类型的写文件
 	writer := multipart.NewWriter(b)
+	if err := f.writeMultipart(writer, f.parts); err != nil {
+		return nil, "", errors.Wrap(err)
+	}
+
+	if f.progress != nil {
+		return f.withProgress(b, nil), writer.FormDataContentType(), nil
+	}
+	return b, writer.FormDataContentType(), nil
+}
+
+// Stream 以流的方式读取Form内容，文件及数据块在请求体被读取时才写入, 不占用额外内存
+//
+//	body 需被完整读取或关闭, 否则写入数据的协程不会退出; 作为 Curl 请求体时由 Curl 负责关闭
+//	数据块(AddFileReader, AddPart)均实现 io.ReaderAt 及 io.Seeker 时 body 可重新生成, 支持 Curl 失败重试及日志预览
+//	重新生成的 body 从添加数据块时的位置独立读取, 不影响正在发送的 body
+func (f *Form) Stream() (body io.ReadCloser, contentType string) {
+	if !f.isMultipart() {
+		return f.withProgress(strings.NewReader(f.Params.Encode()), nil), "application/x-www-form-urlencoded"
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	b := f.stream(boundary)
+	b.form = f
+	return b, "multipart/form-data; boundary=" + boundary
+}
+
+// stream 使用 io.Pipe 流式写入 multipart 内容
+func (f *Form) stream(boundary string) *progressBody {
+	parts, ok := sectionParts(f.parts)
+	if !ok {
+		return f.pipe(boundary, f.parts, func() (io.ReadCloser, error) {
+			return nil, errors.New("multipart 数据块需实现 io.ReaderAt 及 io.Seeker 才支持重复读取")
+		})
+	}
+
+	// 每次生成新的 io.SectionReader, 多个请求体互不影响
+	var getBody func() (io.ReadCloser, error)
+	getBody = func() (io.ReadCloser, error) {
+		fresh := make([]formPart, len(parts))
+		for i, p := range parts {
+			s := p.reader.(*io.SectionReader)
+			fresh[i] = formPart{header: p.header, reader: io.NewSectionReader(s, 0, s.Size())}
+		}
+		return f.pipe(boundary, fresh, getBody), nil
+	}
+	return f.pipe(boundary, parts, getBody)
+}
+
+// pipe 启动协程将 multipart 内容写入 io.Pipe
+func (f *Form) pipe(boundary string, parts []formPart, getBody func() (io.ReadCloser, error)) *progressBody {
+	pr, pw := io.Pipe()
+	go func() {
+		writer := multipart.NewWriter(pw)
+		_ = writer.SetBoundary(boundary)
+		pw.CloseWithError(f.writeMultipart(writer, parts))
+	}()
+	return f.withProgress(pr, getBody)
+}
 
+// sectionParts 数据块均实现 io.ReaderAt 及 io.Seeker 时转换为从当前位置读取的 io.SectionReader
+func sectionParts(parts []formPart) ([]formPart, bool) {
+	sections := make([]formPart, len(parts))
+	for i, p := range parts {
+		ra, ok := p.reader.(io.ReaderAt)
+		if !ok {
+			return nil, false
+		}
+		seeker, ok := p.reader.(io.Seeker)
+		if !ok {
+			return nil, false
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, false
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, false
+		}
+		if _, err = seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, false
+		}
+		sections[i] = formPart{header: p.header, reader: io.NewSectionReader(ra, offset, end-offset)}
+	}
+	return sections, true
+}
+
+// writeMultipart 写入表单、文件及数据块
+func (f *Form) writeMultipart(writer *multipart.Writer, parts []formPart) error {
 	// 处理表单
 	if f.Params != nil {
 		for key, values := range f.Params {
 			for _, value := range values {
 				if err := writer.WriteField(key, value); err != nil {
-					return nil, "", errors.Wrap(err)
+					return errors.Wrap(err)
 				}
 			}
 		}
@@ -1197,25 +1855,132 @@ func (f *Form) Reader() (body io.Reader, contentType string, err error) {
 	for fieldName, files := range f.Files {
 		for _, file := range files {
 			if err := createFormFile(writer, fieldName, file); err != nil {
-				return nil, "", errors.Wrap(err)
+				return errors.Wrap(err)
 			}
 		}
 	}
 
+	// 处理数据块
+	for _, p := range parts {
+		part, err := writer.CreatePart(p.header)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if _, err = io.Copy(part, p.reader); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
 	// 关闭
-	if err := writer.Close(); err != nil {
-		return nil, "", errors.Wrap(err)
+	return errors.Wrap(writer.Close())
+}
+
+// withProgress 包装请求体, 统计读取字节数并回调上传进度
+//
+//	getBody 重新生成请求体方法: 为nil时请求体需是 *bytes.Buffer, *bytes.Reader 或 *strings.Reader
+//	重新生成的请求体使用独立的 io.SectionReader 读取, 不影响原请求体
+func (f *Form) withProgress(r io.Reader, getBody func() (io.ReadCloser, error)) *progressBody {
+	switch v := r.(type) {
+	case *bytes.Buffer:
+		r = io.NewSectionReader(bytes.NewReader(v.Bytes()), 0, int64(v.Len()))
+	case *bytes.Reader:
+		r = io.NewSectionReader(v, v.Size()-int64(v.Len()), int64(v.Len()))
+	case *strings.Reader:
+		r = io.NewSectionReader(v, v.Size()-int64(v.Len()), int64(v.Len()))
+	}
+
+	total := int64(-1)
+	if s, ok := r.(*io.SectionReader); ok {
+		total = s.Size()
+		getBody = func() (io.ReadCloser, error) {
+			return f.withProgress(io.NewSectionReader(s, 0, s.Size()), nil), nil
+		}
 	}
 
-	return b, writer.FormDataContentType(), nil
+	return &progressBody{
+		r:       r,
+		getBody: getBody,
+		w: &progressWriter{
+			total:    total,
+			start:    time.Now(),
+			progress: DownloadProgress(f.progress),
+			interval: 200 * time.Millisecond,
+		},
+	}
+}
+
+// progressBody 统计读取字节数并回调进度的请求体
+type progressBody struct {
+	r       io.Reader
+	w       *progressWriter
+	getBody func() (io.ReadCloser, error)
+	done    bool
+
+	// 生成 multipart 请求体的 Form, 用于 ToCurlCommand 导出 -F 选项
+	form *Form
+}
+
+// Read 实现 io.Reader 接口
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if n > 0 {
+		_, _ = b.w.Write(p[:n])
+	}
+	if err == io.EOF && !b.done {
+		b.done = true
+		b.w.report(true)
+	}
+	return n, err
+}
+
+// Close 实现 io.Closer 接口
+func (b *progressBody) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
-// dumpRequestSafe 请求日志安全预览
-func dumpRequestSafe(req *http.Request, limit int64) (string, error) {
-	dump, err := httputil.DumpRequestOut(req, false)
+// Len 未读取的字节数, 用于 http.NewRequest 设置 ContentLength
+func (b *progressBody) Len() int {
+	if s, ok := b.r.(*io.SectionReader); ok {
+		offset, _ := s.Seek(0, io.SeekCurrent)
+		return int(s.Size() - offset)
+	}
+	return 0
+}
+
+// GetBody 重新生成请求体, 实现 bodyGetter 接口
+func (b *progressBody) GetBody() (io.ReadCloser, error) {
+	if b.getBody == nil {
+		return nil, errors.New("请求体不支持重复读取")
+	}
+	return b.getBody()
+}
+
+// multipartDisposition 生成数据块 Content-Disposition
+func multipartDisposition(fieldName, fileName string) string {
+	quote := strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+	return fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quote.Replace(fieldName), quote.Replace(fileName))
+}
+
+// dumpRequestSafe 请求日志安全预览, 使用 r 脱敏
+func dumpRequestSafe(req *http.Request, limit int64, r *Redactor) (string, error) {
+	out := req
+	if r != nil {
+		// 复制 Request 脱敏, 不修改原请求
+		out = new(http.Request)
+		*out = *req
+		out.Header = r.Header(req.Header)
+		out.URL = r.redactURL(req.URL)
+	}
+	dump, err := httputil.DumpRequestOut(out, false)
 	if err != nil {
 		return "", errors.Wrap(err)
 	}
+	if r != nil {
+		dump = []byte(r.pattern(string(dump)))
+	}
 
 	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
 		return string(dump), nil
@@ -1227,7 +1992,7 @@ func dumpRequestSafe(req *http.Request, limit int64) (string, error) {
 
 	body, err := req.GetBody()
 	if err != nil {
-		return "", errors.Wrap(err)
+		return string(dump) + "\nRequest Body: [skipped: non-rewindable]", nil
 	}
 	defer body.Close()
 
@@ -1236,12 +2001,19 @@ func dumpRequestSafe(req *http.Request, limit int64) (string, error) {
 		return "", errors.Wrap(err)
 	}
 
+	preview = r.Body(req.Header.Get("Content-Type"), preview)
 	return formatDumpWithBody(string(dump), "Request Body", preview, truncated), nil
 }
 
-// dumpResponseSafe 响应日志安全预览
-func dumpResponseSafe(resp *http.Response, limit int64) (string, error) {
-	dump, err := httputil.DumpResponse(resp, false)
+// dumpResponseSafe 响应日志安全预览, 使用 r 脱敏
+func dumpResponseSafe(resp *http.Response, limit int64, r *Redactor) (string, error) {
+	out := resp
+	if r != nil {
+		out = new(http.Response)
+		*out = *resp
+		out.Header = r.Header(resp.Header)
+	}
+	dump, err := httputil.DumpResponse(out, false)
 	if err != nil {
 		return "", errors.Wrap(err)
 	}
@@ -1256,6 +2028,7 @@ func dumpResponseSafe(resp *http.Response, limit int64) (string, error) {
 	}
 	resp.Body = restored
 
+	preview = r.Body(resp.Header.Get("Content-Type"), preview)
 	return formatDumpWithBody(string(dump), "Response Body", preview, truncated), nil
 }
 
@@ -1285,7 +2058,10 @@ func readBodyPreviewAndRestore(body io.ReadCloser, limit int64) ([]byte, bool, i
 	if truncated {
 		preview = buf[:limit]
 	}
-	restored := io.NopCloser(io.MultiReader(bytes.NewReader(buf), body))
+	restored := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), body), body}
 	return preview, truncated, restored, nil
 }
 
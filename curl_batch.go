@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// ErrBatchAborted 快速失败模式下, 已有请求失败导致未执行的请求被放弃
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchRequest 批量请求中的单个请求
+type BatchRequest struct {
+	Method  string       // 请求方式: 为空时 GET
+	URL     string       // 请求地址
+	Body    io.Reader    // 请求体: 每个请求需使用独立的 io.Reader
+	Options []CurlOption // 本请求的 Curl 配置项, 在 CurlBatch 公共配置项之后执行, 如 WithCurlHeader
+
+	// 本请求可接受的状态码: 除200及 Curl 已标记的状态码外
+	StatusCode []int
+
+	// 响应体按JSON解码到 Out, 为nil时不解码
+	Out any
+}
+
+// BatchResult 批量请求中单个请求的结果, 与 BatchRequest 顺序一致
+type BatchResult struct {
+	Index      int           // 请求在批量请求中的下标
+	StatusCode int           // 响应状态码: 未收到响应时为0
+	Header     http.Header   // 响应头
+	Body       []byte        // 响应体
+	RequestId  string        // 请求ID
+	Err        error         // 请求错误
+	Elapsed    time.Duration // 请求耗时
+}
+
+// CurlBatch 并发执行批量请求, 所有请求共享连接池及限流器
+//
+//	每个请求使用独立的 Curl 实例, 可安全并发; 公共配置项通过 WithBatchCurlOptions 设置
+type CurlBatch struct {
+	// 并发数
+	workers int
+
+	// 快速失败: true 任一请求失败后取消其它请求; false 执行所有请求并收集结果
+	failFast bool
+
+	// 每个请求的公共 Curl 配置项
+	opts []CurlOption
+
+	// 共享 Transport 的连接池: nil 使用 DefaultTransportPool
+	pool *TransportPool
+
+	// 共享的限流器: nil 不限流
+	limiter *HostLimiter
+}
+
+// BatchOption CurlBatch配置项
+type BatchOption func(*CurlBatch)
+
+// WithBatchWorkers 设置并发数, 默认: 8
+func WithBatchWorkers(n int) BatchOption {
+	return func(b *CurlBatch) {
+		b.workers = max(n, 1)
+	}
+}
+
+// WithBatchFailFast 设置是否快速失败, 默认: false 执行所有请求并收集结果
+func WithBatchFailFast(failFast bool) BatchOption {
+	return func(b *CurlBatch) {
+		b.failFast = failFast
+	}
+}
+
+// WithBatchCurlOptions 设置每个请求的公共 Curl 配置项, 如超时时间、请求头、重试策略
+//
+//	限流配置项(WithCurlRateLimit, WithCurlMaxConcurrent)在创建 CurlBatch 时只创建一个限流器, 所有请求共享;
+//	BatchRequest.Options 中的限流配置项修改共享的限流器
+func WithBatchCurlOptions(opts ...CurlOption) BatchOption {
+	return func(b *CurlBatch) {
+		b.opts = append(b.opts, opts...)
+	}
+}
+
+// WithBatchTransportPool 设置共享 Transport 的连接池
+func WithBatchTransportPool(pool *TransportPool) BatchOption {
+	return func(b *CurlBatch) {
+		b.pool = pool
+	}
+}
+
+// WithBatchLimiter 设置共享的限流器
+func WithBatchLimiter(limiter *HostLimiter) BatchOption {
+	return func(b *CurlBatch) {
+		b.limiter = limiter
+	}
+}
+
+// WithBatchRateLimit 设置每个 Host 每秒请求数及令牌桶容量, 未设置限流器时创建一个新的限流器
+//
+//	已通过 WithBatchLimiter 设置共享的限流器时, 修改对共享该限流器的所有 Curl 生效
+func WithBatchRateLimit(rps float64, burst int) BatchOption {
+	return func(b *CurlBatch) {
+		if b.limiter == nil {
+			b.limiter = NewHostLimiter(rps, burst, 0)
+		} else {
+			b.limiter.SetRate(rps, burst)
+		}
+	}
+}
+
+// NewCurlBatch 实例化CurlBatch
+func NewCurlBatch(opts ...BatchOption) *CurlBatch {
+	b := &CurlBatch{
+		workers: 8,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(b)
+		}
+	}
+
+	// 公共配置项创建的限流器: 所有请求共享, 不为每个请求创建
+	if b.limiter == nil && len(b.opts) > 0 {
+		b.limiter = NewCurl(b.opts...).GetLimiter()
+	}
+	return b
+}
+
+// Do 并发执行批量请求, 返回与 reqs 顺序一致的结果
+//
+//	快速失败模式: 返回最先失败的请求的错误, 执行中的请求被取消, 未执行的请求错误为 ErrBatchAborted
+//	收集模式: 返回下标最小的失败请求的错误, 所有请求均成功时返回nil
+func (b *CurlBatch) Do(ctx context.Context, reqs []BatchRequest) ([]BatchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	results := make([]BatchResult, len(reqs))
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		jobs     = make(chan int)
+	)
+
+	for range min(b.workers, len(reqs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &results[i]
+				r.Index = i
+
+				// 已取消: 不再发起请求
+				if runCtx.Err() != nil {
+					r.Err = errors.Wrap(Ternary(ctx.Err() != nil, ctx.Err(), ErrBatchAborted))
+					continue
+				}
+
+				b.do(runCtx, &reqs[i], r)
+				if r.Err != nil && b.failFast {
+					once.Do(func() {
+						firstErr = r.Err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for i := range reqs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, errors.Wrap(firstErr)
+	}
+	for i := range results {
+		if results[i].Err != nil {
+			return results, errors.Wrapf(results[i].Err, "batch request %d failed", i)
+		}
+	}
+	return results, nil
+}
+
+// do 执行单个请求
+func (b *CurlBatch) do(ctx context.Context, req *BatchRequest, r *BatchResult) {
+	c := NewCurl(b.opts...)
+	if b.pool != nil {
+		c.SetTransportPool(b.pool)
+	}
+	if b.limiter != nil {
+		c.SetLimiter(b.limiter)
+	}
+	for _, opt := range req.Options {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	r.RequestId = c.GetRequestId()
+
+	hooks := sendHooks{
+		statusCode: req.StatusCode,
+		body: func(resp *http.Response, body []byte) error {
+			r.StatusCode, r.Header, r.Body = resp.StatusCode, resp.Header, body
+
+			// 无响应内容
+			if req.Out == nil || len(body) == 0 {
+				return nil
+			}
+			if err := decodeJSON(body, req.Out); err != nil {
+				return fillDecodeError(newDecodeError(err, body, c.dumpBodyLimit), resp)
+			}
+			return nil
+		},
+	}
+
+	t := time.Now()
+	err := c.send(ctx, Ternary(req.Method == "", http.MethodGet, req.Method), req.URL, req.Body, hooks)
+	r.Elapsed = time.Since(t)
+	if err != nil {
+		// 状态码错误
+		var he *HTTPError
+		if errors.As(err, &he) {
+			r.StatusCode, r.Header, r.Body = he.StatusCode, he.Header, he.Body
+		}
+		r.Err = err
+	}
+}
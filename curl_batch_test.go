@@ -0,0 +1,158 @@
+package utils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestCurlBatch(t *testing.T) {
+	var running, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		utils.Json(w).Success(10000, r.URL.Query().Get("id"))
+	}))
+	defer srv.Close()
+
+	type result struct {
+		Data string `json:"data"`
+	}
+
+	t.Run("CollectAll", func(t *testing.T) {
+		pool := utils.NewTransportPool()
+		batch := utils.NewCurlBatch(
+			utils.WithBatchWorkers(3),
+			utils.WithBatchTransportPool(pool),
+			utils.WithBatchCurlOptions(utils.WithCurlMaxRetry(0)),
+		)
+
+		outs := make([]result, 10)
+		reqs := make([]utils.BatchRequest, 10)
+		for i := range reqs {
+			reqs[i] = utils.BatchRequest{URL: srv.URL + "/?id=" + strconv.Itoa(i), Out: &outs[i]}
+		}
+		reqs[4].URL = srv.URL + "/fail"
+
+		peak.Store(0)
+		results, err := batch.Do(context.Background(), reqs)
+		var he *utils.HTTPError
+		if !errors.As(err, &he) || he.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Do() error = %v, want HTTPError 500", err)
+		}
+		if p := peak.Load(); p > 3 {
+			t.Errorf("peak concurrency = %d, want <= 3", p)
+		}
+		for i, r := range results {
+			if r.Index != i || r.RequestId == "" {
+				t.Errorf("results[%d] = %+v", i, r)
+			}
+			if i == 4 {
+				if r.Err == nil || r.StatusCode != http.StatusInternalServerError {
+					t.Errorf("results[%d] = %+v, want error", i, r)
+				}
+				continue
+			}
+			if r.Err != nil || r.StatusCode != http.StatusOK || outs[i].Data != strconv.Itoa(i) {
+				t.Errorf("results[%d] = %+v, out = %+v", i, r, outs[i])
+			}
+		}
+		if stats := pool.Stats(); stats.Transports != 1 || stats.Dials > 3 {
+			t.Errorf("pool stats = %+v", stats)
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		batch := utils.NewCurlBatch(
+			utils.WithBatchWorkers(2),
+			utils.WithBatchFailFast(true),
+			utils.WithBatchCurlOptions(utils.WithCurlMaxRetry(0)),
+		)
+		reqs := []utils.BatchRequest{
+			{URL: srv.URL + "/slow"},
+			{URL: srv.URL + "/fail"},
+			{URL: srv.URL + "/"},
+			{URL: srv.URL + "/"},
+		}
+
+		start := time.Now()
+		results, err := batch.Do(context.Background(), reqs)
+		if spent := time.Since(start); spent > time.Second {
+			t.Errorf("Do() time spent = %v, want slow request canceled", spent)
+		}
+		var he *utils.HTTPError
+		if !errors.As(err, &he) {
+			t.Fatalf("Do() error = %v, want HTTPError", err)
+		}
+		if !errors.Is(results[0].Err, context.Canceled) {
+			t.Errorf("results[0].Err = %v, want %v", results[0].Err, context.Canceled)
+		}
+		for _, r := range results[2:] {
+			if !errors.Is(r.Err, utils.ErrBatchAborted) {
+				t.Errorf("results[%d].Err = %v, want %v", r.Index, r.Err, utils.ErrBatchAborted)
+			}
+		}
+	})
+
+	t.Run("Limiter", func(t *testing.T) {
+		// 公共配置项的限流器由所有请求共享
+		var running, peak atomic.Int32
+		limitSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n := running.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+		}))
+		defer limitSrv.Close()
+
+		batch := utils.NewCurlBatch(
+			utils.WithBatchWorkers(4),
+			utils.WithBatchCurlOptions(utils.WithCurlMaxConcurrent(1)),
+		)
+		reqs := make([]utils.BatchRequest, 4)
+		for i := range reqs {
+			reqs[i] = utils.BatchRequest{URL: limitSrv.URL}
+		}
+		if _, err := batch.Do(context.Background(), reqs); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if p := peak.Load(); p != 1 {
+			t.Errorf("peak concurrency = %d, want 1", p)
+		}
+	})
+
+	t.Run("StatusCode", func(t *testing.T) {
+		results, err := utils.NewCurlBatch().Do(context.Background(), []utils.BatchRequest{
+			{URL: srv.URL + "/fail", StatusCode: []int{http.StatusInternalServerError}},
+		})
+		if err != nil || results[0].StatusCode != http.StatusInternalServerError {
+			t.Errorf("Do() = %+v, %v", results, err)
+		}
+	})
+}
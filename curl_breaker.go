@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态, 请求被拒绝
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态
+type CircuitState int8
+
+const (
+	CircuitClosed   CircuitState = iota // 0 关闭: 正常请求
+	CircuitOpen                         // 1 打开: 拒绝请求
+	CircuitHalfOpen                     // 2 半开: 允许少量探测请求
+)
+
+// String 实现 fmt.Stringer 接口
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker 按请求的 Host 熔断, 可在多个 Curl 实例间共享
+//
+//	关闭状态: 统计窗口内请求数达到 minRequests 且失败率达到 failureRatio 时打开
+//	打开状态: 拒绝请求并返回 ErrCircuitOpen, 冷却时间后进入半开状态
+//	半开状态: 允许 halfOpenRequests 个探测请求, 全部成功则关闭, 任一失败则重新打开;
+//	         探测请求因调用方 ctx 取消或超时结束时不计入结果, 释放探测名额
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	// 失败率阈值
+	failureRatio float64
+
+	// 统计窗口内最少请求数
+	minRequests int
+
+	// 统计窗口
+	window time.Duration
+
+	// 打开状态冷却时间
+	coolDown time.Duration
+
+	// 半开状态探测请求数
+	halfOpenRequests int
+
+	// 判断请求是否失败
+	isFailure func(resp *http.Response, err error) bool
+
+	// 状态变更回调方法
+	onStateChange func(host string, from, to CircuitState)
+
+	// 每个 Host 的熔断状态
+	hosts map[string]*circuit
+}
+
+// circuit 单个 Host 的熔断状态
+type circuit struct {
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // 半开状态已放行的探测请求数
+	successes   int // 半开状态探测成功数
+}
+
+// BreakerOption CircuitBreaker配置项
+type BreakerOption func(*CircuitBreaker)
+
+// WithBreakerFailureRatio 设置失败率阈值及统计窗口内最少请求数, 默认: 0.5, 10
+func WithBreakerFailureRatio(ratio float64, minRequests int) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if ratio > 0 && ratio <= 1 {
+			b.failureRatio = ratio
+		}
+		if minRequests > 0 {
+			b.minRequests = minRequests
+		}
+	}
+}
+
+// WithBreakerWindow 设置统计窗口, 默认: 10秒
+func WithBreakerWindow(window time.Duration) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if window > 0 {
+			b.window = window
+		}
+	}
+}
+
+// WithBreakerCoolDown 设置打开状态冷却时间, 默认: 30秒
+func WithBreakerCoolDown(coolDown time.Duration) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if coolDown > 0 {
+			b.coolDown = coolDown
+		}
+	}
+}
+
+// WithBreakerHalfOpenRequests 设置半开状态探测请求数, 默认: 1
+func WithBreakerHalfOpenRequests(n int) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if n > 0 {
+			b.halfOpenRequests = n
+		}
+	}
+}
+
+// WithBreakerIsFailure 设置判断请求是否失败的方法, 默认: 传输错误(不含 context 取消)或状态码 >= 500
+func WithBreakerIsFailure(f func(resp *http.Response, err error) bool) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if f != nil {
+			b.isFailure = f
+		}
+	}
+}
+
+// WithBreakerStateChange 设置状态变更回调方法, 如记录监控指标
+//
+//	回调在持有锁时同步执行, 不应执行耗时操作
+func WithBreakerStateChange(f func(host string, from, to CircuitState)) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.onStateChange = f
+	}
+}
+
+// NewCircuitBreaker 实例化CircuitBreaker
+func NewCircuitBreaker(opts ...BreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{
+		failureRatio:     0.5,
+		minRequests:      10,
+		window:           10 * time.Second,
+		coolDown:         30 * time.Second,
+		halfOpenRequests: 1,
+		isFailure:        defaultIsFailure,
+		hosts:            make(map[string]*circuit),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(b)
+		}
+	}
+	return b
+}
+
+// defaultIsFailure 默认判断请求是否失败: 传输错误(不含 context 取消)或状态码 >= 500
+func defaultIsFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// State 获取 Host 的熔断状态
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.circuit(host, time.Now()).state
+}
+
+// Allow 判断是否允许请求 Host, 允许时请求完成后需调用 report 上报请求结果
+//
+//	熔断器打开时返回 ErrCircuitOpen
+func (b *CircuitBreaker) Allow(host string) (report func(resp *http.Response, err error), err error) {
+	return b.AllowContext(context.Background(), host)
+}
+
+// AllowContext 同 Allow, ctx 为请求的 context
+//
+//	半开状态的探测请求失败且 ctx 已取消或超时时, 不计入成功或失败, 释放探测名额
+func (b *CircuitBreaker) AllowContext(ctx context.Context, host string) (report func(resp *http.Response, err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host, time.Now())
+	switch c.state {
+	case CircuitOpen:
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if c.probes >= b.halfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+		c.probes++
+	}
+
+	state := c.state
+	return func(resp *http.Response, err error) {
+		// 调用方放弃请求, 无法判断 Host 是否恢复
+		if err != nil && ctx.Err() != nil && state == CircuitHalfOpen {
+			b.release(host)
+			return
+		}
+		b.report(host, state, b.isFailure(resp, err))
+	}, nil
+}
+
+// circuit 获取 Host 的熔断状态并处理状态过期, 调用方需持有锁
+func (b *CircuitBreaker) circuit(host string, now time.Time) *circuit {
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{state: CircuitClosed, windowStart: now}
+		b.hosts[host] = c
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		// 新的统计窗口
+		if now.Sub(c.windowStart) >= b.window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+	case CircuitOpen:
+		// 冷却时间已过, 进入半开状态
+		if now.Sub(c.openedAt) >= b.coolDown {
+			b.setState(host, c, CircuitHalfOpen, now)
+		}
+	}
+	return c
+}
+
+// report 上报请求结果
+func (b *CircuitBreaker) report(host string, state CircuitState, failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	c := b.circuit(host, now)
+
+	// 请求期间状态已变更, 忽略本次结果
+	if c.state != state {
+		return
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		c.requests++
+		if failure {
+			c.failures++
+		}
+		if c.requests >= b.minRequests && float64(c.failures)/float64(c.requests) >= b.failureRatio {
+			b.setState(host, c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failure {
+			b.setState(host, c, CircuitOpen, now)
+			return
+		}
+		c.successes++
+		if c.successes >= b.halfOpenRequests {
+			b.setState(host, c, CircuitClosed, now)
+		}
+	}
+}
+
+// release 释放半开状态的探测名额
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 请求期间状态已变更, 探测名额已重置
+	if c := b.circuit(host, time.Now()); c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// setState 变更熔断状态并重置统计, 调用方需持有锁
+func (b *CircuitBreaker) setState(host string, c *circuit, state CircuitState, now time.Time) {
+	from := c.state
+	c.state = state
+	c.windowStart, c.requests, c.failures = now, 0, 0
+	c.probes, c.successes = 0, 0
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+	if b.onStateChange != nil && from != state {
+		b.onStateChange(host, from, state)
+	}
+}
@@ -0,0 +1,136 @@
+package utils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	var changes []string
+	breaker := utils.NewCircuitBreaker(
+		utils.WithBreakerFailureRatio(0.5, 2),
+		utils.WithBreakerCoolDown(50*time.Millisecond),
+		utils.WithBreakerStateChange(func(host string, from, to utils.CircuitState) {
+			if host != u.Host {
+				t.Errorf("StateChange() host = %v, want %v", host, u.Host)
+			}
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	)
+	curl := utils.NewCurl(utils.WithCurlCircuitBreaker(breaker), utils.WithCurlMaxRetry(1))
+
+	tests := []struct {
+		name      string
+		path      string
+		sleep     time.Duration
+		wantErr   error
+		wantHits  int32
+		wantState utils.CircuitState
+	}{
+		{name: "001", path: "/fail", wantHits: 1, wantState: utils.CircuitClosed},
+		{name: "002", path: "/fail", wantHits: 2, wantState: utils.CircuitOpen},
+		{name: "003", path: "/", wantErr: utils.ErrCircuitOpen, wantHits: 2, wantState: utils.CircuitOpen},
+		{name: "004", path: "/", sleep: 60 * time.Millisecond, wantHits: 3, wantState: utils.CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.sleep)
+			err := curl.Get(srv.URL + tt.path)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("Get() hits = %v, want %v", got, tt.wantHits)
+			}
+			if got := breaker.State(u.Host); got != tt.wantState {
+				t.Errorf("State() = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("StateChange() = %v, want %v", changes, want)
+	}
+}
+
+func TestCircuitBreakerProbeContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		// 调用方取消
+		{name: "001", ctx: func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+		// 调用方超时
+		{name: "002", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := utils.NewCircuitBreaker(utils.WithBreakerFailureRatio(0.5, 1), utils.WithBreakerCoolDown(20*time.Millisecond))
+			curl := utils.NewCurl(utils.WithCurlCircuitBreaker(breaker), utils.WithCurlMaxRetry(1))
+			_ = curl.Get(srv.URL + "/fail")
+			time.Sleep(30 * time.Millisecond)
+
+			// 探测请求因 ctx 结束: 不计入结果, 保持半开状态
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			if err := curl.SendContext(ctx, http.MethodGet, srv.URL+"/slow", nil); err == nil {
+				t.Fatalf("SendContext() error = nil")
+			}
+			if got := breaker.State(u.Host); got != utils.CircuitHalfOpen {
+				t.Fatalf("State() = %v, want %v", got, utils.CircuitHalfOpen)
+			}
+
+			// 探测名额已释放
+			if err := curl.Get(srv.URL); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := breaker.State(u.Host); got != utils.CircuitClosed {
+				t.Errorf("State() = %v, want %v", got, utils.CircuitClosed)
+			}
+		})
+	}
+}
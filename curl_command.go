@@ -0,0 +1,648 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// ToCurlCommand 将请求转换为等效的 curl 命令, 便于复现请求
+//
+//	包含请求头、Cookie、Basic认证、请求参数、请求体、代理、证书及跳过https不安全验证的配置
+//	请求体通过 SetBody 设置: 可重新生成或可随机读取的请求体不影响后续发送, 其它请求体读取后重新设置为可再次读取的请求体
+//	Form.Stream 生成的 multipart 请求体导出为 -F 选项(由 curl 生成 Content-Type); 含 AddFileReader, AddPart 数据块时导出为 --data-raw
+func (c *Curl) ToCurlCommand(method, url string) (string, error) {
+	url, err := UrlPath(url, c.params)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+
+	// 每行一个选项
+	args := make([]string, 0, len(c.header)+8)
+	add := func(option string, values ...string) {
+		args = append(args, strings.Join(append([]string{option}, values...), " "))
+	}
+
+	// 以 -F 导出的 multipart 表单
+	var (
+		form *Form
+		body []byte
+	)
+	if b, ok := c.body.(*progressBody); ok && b.form != nil && len(b.form.parts) == 0 {
+		form = b.form
+	} else if body, err = c.readBody(); err != nil {
+		return "", errors.Wrap(err)
+	}
+
+	switch method = strings.ToUpper(Ternary(method == "", http.MethodGet, method)); {
+	case method == http.MethodGet && form == nil && len(body) == 0:
+		add("curl", shellQuote(url))
+	case method == http.MethodHead:
+		add("curl -I", shellQuote(url))
+	default:
+		// 携带请求体的 GET 请求需指定 -X GET, 否则 curl 使用 POST
+		add("curl -X "+method, shellQuote(url))
+	}
+
+	// 请求头
+	for _, key := range sortedKeys(c.header) {
+		// -F 由 curl 生成 multipart 分隔符
+		if form != nil && key == "Content-Type" {
+			continue
+		}
+		for _, value := range c.header[key] {
+			add("-H", shellQuote(key+": "+value))
+		}
+	}
+
+	// Cookie
+	if len(c.cookies) > 0 {
+		names := make([]string, 0, len(c.cookies))
+		for name := range c.cookies {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		cookies := make([]string, 0, len(names))
+		for _, name := range names {
+			cookies = append(cookies, c.cookies[name].Name+"="+c.cookies[name].Value)
+		}
+		add("-b", shellQuote(strings.Join(cookies, "; ")))
+	}
+
+	// Basic认证
+	if c.username != "" && c.password != "" {
+		add("-u", shellQuote(c.username+":"+c.password))
+	}
+
+	// 代理、证书
+	if c.proxyURL != "" {
+		add("-x", shellQuote(c.proxyURL))
+	}
+	if c.insecureSkipVerify {
+		add("-k")
+	}
+	if c.rootCAs != "" {
+		add("--cacert", shellQuote(c.rootCAs))
+	}
+	if c.cert != "" && c.key != "" {
+		add("--cert", shellQuote(c.cert), "--key", shellQuote(c.key))
+	}
+
+	// 超时时间
+	if c.timeout > 0 && c.timeout != 30*time.Second {
+		add("--max-time", strconv.FormatFloat(c.timeout.Seconds(), 'f', -1, 64))
+	}
+
+	// 请求体
+	if form != nil {
+		for _, key := range sortedKeys(form.Params) {
+			for _, value := range form.Params[key] {
+				// 以 @ < 开头或包含 ; 的值按字符串发送
+				if strings.HasPrefix(value, "@") || strings.HasPrefix(value, "<") || strings.Contains(value, ";") {
+					add("--form-string", shellQuote(key+"="+value))
+				} else {
+					add("-F", shellQuote(key+"="+value))
+				}
+			}
+		}
+		for _, key := range sortedKeys(form.Files) {
+			for _, file := range form.Files[key] {
+				add("-F", shellQuote(key+"=@"+file))
+			}
+		}
+	} else if len(body) > 0 {
+		add("--data-raw", shellQuote(string(body)))
+	}
+
+	return strings.Join(args, " \\\n  "), nil
+}
+
+// readBody 读取请求体, 不影响后续发送的请求体
+//
+//	可重新生成或可随机读取的请求体独立读取; 其它请求体读取后重新设置为可再次读取的请求体
+func (c *Curl) readBody() ([]byte, error) {
+	if c.body == nil {
+		return nil, nil
+	}
+
+	// 可重新生成的请求体, 如 Form.Stream; GetBody 返回独立的请求体
+	if gb, ok := c.body.(bodyGetter); ok {
+		r, err := gb.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		return b, errors.Wrap(err)
+	}
+
+	// 可随机读取的请求体, 如 *bytes.Reader, *os.File: 从当前位置读取, 不改变读取位置
+	if ra, ok := c.body.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		if offset, end, ok := seekRange(ra); ok {
+			b, err := io.ReadAll(io.NewSectionReader(ra, offset, end-offset))
+			return b, errors.Wrap(err)
+		}
+	}
+
+	b, err := io.ReadAll(c.body)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	c.body = bytes.NewReader(b)
+	return b, nil
+}
+
+// sortedKeys 排序后的键
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// isCurlProgram 判断是否是 curl 程序, 如 curl, /usr/bin/curl, curl.exe, C:\Windows\System32\curl.exe
+func isCurlProgram(name string) bool {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".exe") {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name == "curl"
+}
+
+// CurlCommand 由 curl 命令解析的请求
+type CurlCommand struct {
+	*Curl
+
+	Method string // 请求方式
+	URL    string // 请求地址
+}
+
+// Do 发起解析的请求
+func (cc *CurlCommand) Do() error {
+	return cc.DoContext(context.Background())
+}
+
+// DoContext 携带 context 发起解析的请求
+func (cc *CurlCommand) DoContext(ctx context.Context) error {
+	return cc.SendContext(ctx, cc.Method, cc.URL, cc.body)
+}
+
+// NewCurlFromCommand 解析 curl 命令(如浏览器开发者工具中 Copy as cURL 的内容), 创建配置好的 Curl
+//
+//	支持: -X, -H, -b, -u, -d, --data-raw, --data-binary, --data-urlencode, --json, -F, -G, -I, -A, -e, -x, -k, --cacert, --cert, --key, -m, --url
+//	忽略不影响请求内容的选项, 如 -s, -v, -L, --compressed; 其它不支持的选项返回错误
+//	opts 在解析的配置之前执行, 如 WithCurlLogger
+func NewCurlFromCommand(command string, opts ...CurlOption) (*CurlCommand, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if len(args) == 0 || !isCurlProgram(args[0]) {
+		return nil, errors.New("not a curl command")
+	}
+
+	c := NewCurl(opts...)
+	c.DelHeaders("Content-Type")
+
+	var (
+		method, rawURL string
+		data           []string
+		form           *Form
+		get, isJSON    bool
+	)
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		// 请求地址
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rawURL = arg
+			continue
+		}
+
+		name, value, hasValue := splitCurlOption(arg)
+		if name == "" {
+			return nil, errors.Errorf("unsupported curl option: %s", arg)
+		}
+
+		// 选项值
+		if curlValueOptions[name] && !hasValue {
+			if i++; i >= len(args) {
+				return nil, errors.Errorf("curl option %s requires a value", arg)
+			}
+			value = args[i]
+		}
+
+		switch name {
+		case "--request":
+			method = strings.ToUpper(value)
+		case "--url":
+			rawURL = value
+		case "--header":
+			key, val, _ := strings.Cut(value, ":")
+			key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+			if strings.EqualFold(key, "X-Request-Id") {
+				c.SetRequestId(val)
+			} else {
+				c.AddHeader(key, val)
+			}
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, errors.Errorf("unsupported cookie file: %s", value)
+			}
+			for _, pair := range strings.Split(value, ";") {
+				if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && k != "" {
+					c.AddCookies(&http.Cookie{Name: k, Value: v})
+				}
+			}
+		case "--user":
+			username, password, _ := strings.Cut(value, ":")
+			c.SetBasicAuth(username, password)
+		case "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			if name == "--json" {
+				isJSON = true
+			}
+			v, err := curlData(name, value)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			data = append(data, v)
+		case "--form", "--form-string":
+			if form == nil {
+				form = &Form{Params: make(url.Values), Files: make(url.Values)}
+			}
+			if err = curlFormField(form, name, value); err != nil {
+				return nil, errors.Wrap(err)
+			}
+		case "--get":
+			get = true
+		case "--head":
+			method = http.MethodHead
+		case "--user-agent":
+			c.SetUserAgent(value)
+		case "--referer":
+			c.SetHeader("Referer", value)
+		case "--proxy":
+			c.SetProxyURL(value)
+		case "--insecure":
+			c.InsecureSkipVerify(true)
+		case "--cacert":
+			c.SetRootCAs(value)
+		case "--cert":
+			c.cert = value
+		case "--key":
+			c.key = value
+		case "--max-time":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			c.timeout = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	if rawURL == "" {
+		return nil, errors.New("curl command missing url")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	// 请求体
+	switch {
+	case form != nil:
+		body, contentType := form.Stream()
+		c.SetBody(body).SetContentType(contentType)
+		method = Ternary(method == "", http.MethodPost, method)
+	case len(data) > 0 && get:
+		// -G: 请求数据作为 url 参数
+		sep := Ternary(strings.Contains(rawURL, "?"), "&", "?")
+		rawURL += sep + strings.Join(data, "&")
+	case len(data) > 0:
+		c.SetBody(strings.NewReader(strings.Join(data, Ternary(isJSON, "", "&"))))
+		if !c.HasHeader("Content-Type") {
+			c.SetContentType(Ternary(isJSON, "application/json", "application/x-www-form-urlencoded"))
+		}
+		if isJSON && !c.HasHeader("Accept") {
+			c.SetHeader("Accept", "application/json")
+		}
+		method = Ternary(method == "", http.MethodPost, method)
+	}
+
+	if _, err = url.Parse(rawURL); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	return &CurlCommand{
+		Curl:   c,
+		Method: Ternary(method == "", http.MethodGet, method),
+		URL:    rawURL,
+	}, nil
+}
+
+// curlValueOptions 需要选项值的 curl 选项
+var curlValueOptions = map[string]bool{
+	"--request": true, "--url": true, "--header": true, "--cookie": true, "--user": true,
+	"--data": true, "--data-ascii": true, "--data-binary": true, "--data-raw": true, "--data-urlencode": true, "--json": true,
+	"--form": true, "--form-string": true, "--user-agent": true, "--referer": true, "--proxy": true,
+	"--cacert": true, "--cert": true, "--key": true, "--max-time": true,
+	// 忽略的选项
+	"--output": true, "--connect-timeout": true, "--retry": true, "--write-out": true, "--max-redirs": true, "--cookie-jar": true,
+}
+
+// curlIgnoreOptions 忽略的无选项值的 curl 选项
+var curlIgnoreOptions = map[string]bool{
+	"--compressed": true, "--silent": true, "--show-error": true, "--verbose": true, "--location": true,
+	"--include": true, "--fail": true, "--http1.1": true, "--http2": true, "--no-buffer": true, "--globoff": true,
+}
+
+// curlShortOptions curl 短选项对应的长选项
+var curlShortOptions = map[byte]string{
+	'X': "--request", 'H': "--header", 'b': "--cookie", 'u': "--user", 'd': "--data", 'F': "--form",
+	'G': "--get", 'I': "--head", 'A': "--user-agent", 'e': "--referer", 'x': "--proxy", 'k': "--insecure",
+	'E': "--cert", 'm': "--max-time", 'o': "--output", 'w': "--write-out", 'c': "--cookie-jar",
+	's': "--silent", 'S': "--show-error", 'v': "--verbose", 'L': "--location", 'i': "--include", 'f': "--fail",
+	'N': "--no-buffer", 'g': "--globoff",
+}
+
+// splitCurlOption 解析 curl 选项, 返回长选项名称及附带的选项值(如 -XPOST)
+//
+//	组合的短选项(如 -sSLk)中只保留影响请求的选项, 不支持的选项返回空名称
+func splitCurlOption(arg string) (name, value string, hasValue bool) {
+	if strings.HasPrefix(arg, "--") {
+		if curlValueOptions[arg] || curlIgnoreOptions[arg] || arg == "--get" || arg == "--head" || arg == "--insecure" {
+			return arg, "", false
+		}
+		return "", "", false
+	}
+
+	name = curlShortOptions[arg[1]]
+	if name == "" {
+		return "", "", false
+	}
+	if len(arg) == 2 {
+		return name, "", false
+	}
+
+	// 附带选项值
+	if curlValueOptions[name] {
+		return name, arg[2:], true
+	}
+
+	// 组合的短选项
+	for i := 1; i < len(arg); i++ {
+		opt := curlShortOptions[arg[i]]
+		if opt == "" || curlValueOptions[opt] {
+			return "", "", false
+		}
+		if !curlIgnoreOptions[opt] {
+			name = opt
+		}
+	}
+	return name, "", false
+}
+
+// curlData 解析 -d, --data-raw 等选项的请求数据
+func curlData(name, value string) (string, error) {
+	switch name {
+	case "--data-raw":
+		return value, nil
+	case "--data-urlencode":
+		// name=content, =content, content, name@file, @file
+		if k, v, ok := strings.Cut(value, "="); ok {
+			return Ternary(k == "", "", k+"=") + url.QueryEscape(v), nil
+		}
+		if k, file, ok := strings.Cut(value, "@"); ok {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return "", errors.Wrap(err)
+			}
+			return Ternary(k == "", "", k+"=") + url.QueryEscape(string(b)), nil
+		}
+		return url.QueryEscape(value), nil
+	}
+
+	// @file 读取文件内容
+	if file, ok := strings.CutPrefix(value, "@"); ok {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		if name == "--data" || name == "--data-ascii" {
+			b = bytes.ReplaceAll(bytes.ReplaceAll(b, []byte("\r"), nil), []byte("\n"), nil)
+		}
+		return string(b), nil
+	}
+	return value, nil
+}
+
+// curlFormField 解析 -F 选项: name=value, name=@file, name=<file
+func curlFormField(form *Form, name, value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return errors.Errorf("illegal form field: %s", value)
+	}
+	if name == "--form-string" {
+		form.AddParam(key, val)
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(val, "@"):
+		// 忽略 ;type=, ;filename= 等属性
+		file, _, _ := strings.Cut(val[1:], ";")
+		form.AddFile(key, file)
+	case strings.HasPrefix(val, "<"):
+		file, _, _ := strings.Cut(val[1:], ";")
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		form.AddParam(key, string(b))
+	default:
+		form.AddParam(key, val)
+	}
+	return nil
+}
+
+// splitCommand 按 shell 规则拆分命令参数, 支持单引号、双引号、$'...'、反斜杠转义及续行
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		b       strings.Builder
+		inArg   bool
+		i, size = 0, len(command)
+	)
+
+	for i < size {
+		ch := command[i]
+		switch {
+		case ch == '\\' && i+1 < size && (command[i+1] == '\n' || command[i+1] == '\r'):
+			// 续行
+			i += 2
+			if i < size && command[i-1] == '\r' && command[i] == '\n' {
+				i++
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+			i++
+		case ch == '\\':
+			if i+1 < size {
+				b.WriteByte(command[i+1])
+			}
+			inArg = true
+			i += 2
+		case ch == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			b.WriteString(command[i+1 : i+1+end])
+			inArg = true
+			i += end + 2
+		case ch == '$' && i+1 < size && command[i+1] == '\'':
+			n, err := ansiCQuote(&b, command[i+2:])
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			inArg = true
+			i += n + 2
+		case ch == '"':
+			i++
+			for ; i < size && command[i] != '"'; i++ {
+				// 双引号中只转义 $ ` " \ 及换行
+				if command[i] == '\\' && i+1 < size && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				b.WriteByte(command[i])
+			}
+			if i >= size {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+			i++
+		default:
+			b.WriteByte(ch)
+			inArg = true
+			i++
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
+
+// ansiCQuote 解析 $'...' 的内容写入 b, 返回读取的字节数(含结束的单引号)
+func ansiCQuote(b *strings.Builder, s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return 0, errors.New("unterminated $' quote")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case 'x':
+				if i+2 < len(s) {
+					if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+						b.WriteByte(byte(v))
+						i += 2
+						continue
+					}
+				}
+				b.WriteString(`\x`)
+			case 'u':
+				if i+4 < len(s) {
+					if v, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+						b.WriteRune(rune(v))
+						i += 4
+						continue
+					}
+				}
+				b.WriteString(`\u`)
+			default:
+				// \\ \' \" 等
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $' quote")
+}
+
+// shellQuote 按 shell 规则引用参数: 包含控制字符或非UTF-8内容时使用 $'...'
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/@%+=", r))
+	}) < 0 {
+		return s
+	}
+
+	// 控制字符(换行除外)或非UTF-8内容
+	if !utf8.ValidString(s) || strings.IndexFunc(s, func(r rune) bool {
+		return r < 0x20 && r != '\n' && r != '\t' || r == 0x7f
+	}) >= 0 {
+		var b strings.Builder
+		b.WriteString("$'")
+		for i := 0; i < len(s); i++ {
+			switch ch := s[i]; {
+			case ch == '\\' || ch == '\'':
+				b.WriteByte('\\')
+				b.WriteByte(ch)
+			case ch == '\n':
+				b.WriteString(`\n`)
+			case ch == '\t':
+				b.WriteString(`\t`)
+			case ch == '\r':
+				b.WriteString(`\r`)
+			case ch < 0x20 || ch >= 0x7f:
+				fmt.Fprintf(&b, `\x%02x`, ch)
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		b.WriteByte('\'')
+		return b.String()
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,247 @@
+package utils_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCurlCommand(t *testing.T) {
+	type received struct {
+		method, uri, body, auth, cookie, contentType, custom string
+	}
+	var got received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		user, pass, _ := r.BasicAuth()
+		got = received{
+			method:      r.Method,
+			uri:         r.URL.RequestURI(),
+			body:        string(b),
+			auth:        user + ":" + pass,
+			cookie:      r.Header.Get("Cookie"),
+			contentType: r.Header.Get("Content-Type"),
+			custom:      r.Header.Get("X-Custom"),
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	t.Run("ToCurlCommand", func(t *testing.T) {
+		curl := utils.NewCurl(utils.WithCurlRequestId("req-1"), utils.WithCurlTimeout(5*time.Second)).
+			SetHeader("X-Custom", "it's").
+			SetParam("q", "a b").
+			SetCookies(&http.Cookie{Name: "sid", Value: "abc"}).
+			SetBasicAuth("user", "pass").
+			SetProxyURL("http://127.0.0.1:8080").
+			InsecureSkipVerify(true).
+			SetBodyBytes([]byte("{\"name\":\"it's\"}\n\x01"))
+
+		cmd, err := curl.ToCurlCommand(http.MethodPost, "https://example.com/api")
+		if err != nil {
+			t.Fatalf("ToCurlCommand() error = %v", err)
+		}
+		for _, want := range []string{
+			"curl -X POST 'https://example.com/api?q=a+b' \\\n",
+			`-H 'Content-Type: application/json'`,
+			`-H 'X-Custom: it'\''s'`,
+			`-H 'X-Request-Id: req-1'`,
+			`-b sid=abc`,
+			`-u user:pass`,
+			`-x http://127.0.0.1:8080`,
+			`-k`,
+			`--max-time 5`,
+			`--data-raw $'{"name":"it\'s"}\n\x01'`,
+		} {
+			if !strings.Contains(cmd, want) {
+				t.Errorf("ToCurlCommand() = %s\nwant contains %s", cmd, want)
+			}
+		}
+
+		// 请求体可再次读取
+		if cmd2, _ := curl.ToCurlCommand(http.MethodPost, "https://example.com/api"); cmd2 != cmd {
+			t.Errorf("ToCurlCommand() second = %s, want %s", cmd2, cmd)
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		curl := utils.NewCurl().
+			SetHeader("X-Custom", "a \"b\" $c").
+			SetParam("q", "1").
+			SetCookies(&http.Cookie{Name: "sid", Value: "abc"}).
+			SetBasicAuth("user", "p:ss").
+			SetBodyBytes([]byte(`{"text":"line1\nline2 'q'"}`))
+		cmd, err := curl.ToCurlCommand(http.MethodPut, srv.URL+"/api")
+		if err != nil {
+			t.Fatalf("ToCurlCommand() error = %v", err)
+		}
+		if err = curl.Put(srv.URL + "/api"); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		want := got
+
+		cc, err := utils.NewCurlFromCommand(cmd)
+		if err != nil {
+			t.Fatalf("NewCurlFromCommand() error = %v", err)
+		}
+		if cc.GetRequestId() != curl.GetRequestId() {
+			t.Errorf("RequestId = %s, want %s", cc.GetRequestId(), curl.GetRequestId())
+		}
+		if err = cc.Do(); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("received = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		// 无请求体不指定 -X GET
+		curl := utils.NewCurl()
+		if cmd, _ := curl.ToCurlCommand(http.MethodGet, srv.URL+"/get"); !strings.HasPrefix(cmd, "curl "+srv.URL) || strings.Contains(cmd, "-X") {
+			t.Errorf("ToCurlCommand() = %s", cmd)
+		}
+
+		// 携带请求体的 GET 请求
+		curl.SetBodyBytes([]byte(`{"a":1}`))
+		cmd, err := curl.ToCurlCommand(http.MethodGet, srv.URL+"/get")
+		if err != nil || !strings.HasPrefix(cmd, "curl -X GET ") {
+			t.Fatalf("ToCurlCommand() = %s, %v", cmd, err)
+		}
+		cc, err := utils.NewCurlFromCommand(cmd)
+		if err != nil {
+			t.Fatalf("NewCurlFromCommand() error = %v", err)
+		}
+		if err = cc.Do(); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if got.method != http.MethodGet || got.body != `{"a":1}` {
+			t.Errorf("received = %+v", got)
+		}
+	})
+
+	t.Run("Form", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "a.txt")
+		if err := os.WriteFile(file, []byte("file content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		form := utils.Form{Params: map[string][]string{}, Files: map[string][]string{}}
+		form.SetParam("name", "Lisa").SetParam("at", "@home").SetFile("upload", file)
+		body, contentType := form.Stream()
+		curl := utils.NewCurl(utils.WithCurlContentType(contentType)).SetBody(body)
+
+		cmd, err := curl.ToCurlCommand(http.MethodPost, srv.URL+"/form")
+		if err != nil {
+			t.Fatalf("ToCurlCommand() error = %v", err)
+		}
+		for _, want := range []string{`-F name=Lisa`, `--form-string at=@home`, `-F upload=@` + file} {
+			if !strings.Contains(cmd, want) {
+				t.Errorf("ToCurlCommand() = %s\nwant contains %s", cmd, want)
+			}
+		}
+		if strings.Contains(cmd, "--data-raw") || strings.Contains(cmd, "Content-Type") {
+			t.Errorf("ToCurlCommand() = %s, want -F only", cmd)
+		}
+
+		cc, err := utils.NewCurlFromCommand(cmd)
+		if err != nil {
+			t.Fatalf("NewCurlFromCommand() error = %v", err)
+		}
+		if err = cc.Do(); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if !strings.HasPrefix(got.contentType, "multipart/form-data") || !strings.Contains(got.body, "file content") || !strings.Contains(got.body, "@home") {
+			t.Errorf("received = %+v", got)
+		}
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		// 导出命令不影响后续发送的请求体
+		file, err := os.CreateTemp(t.TempDir(), "body")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		_, _ = file.WriteString("skip:file body")
+		_, _ = file.Seek(5, io.SeekStart)
+
+		curl := utils.NewCurl().SetBody(file)
+		cmd, err := curl.ToCurlCommand(http.MethodPost, srv.URL+"/body")
+		if err != nil || !strings.Contains(cmd, `--data-raw 'file body'`) {
+			t.Fatalf("ToCurlCommand() = %s, %v", cmd, err)
+		}
+		if err = curl.Post(srv.URL + "/body"); err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		if got.body != "file body" {
+			t.Errorf("body = %q, want %q", got.body, "file body")
+		}
+	})
+
+	t.Run("NewCurlFromCommand", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "a.txt")
+		if err := os.WriteFile(file, []byte("file content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name    string
+			command string
+			want    received
+			wantErr bool
+		}{
+			{name: "001", command: `curl '` + srv.URL + `/a' -H 'X-Custom: v' --compressed -sSL`,
+				want: received{method: "GET", uri: "/a", auth: ":", custom: "v"}},
+			{name: "002", command: "curl " + srv.URL + "/b \\\n  -d 'a=1' --data-urlencode 'b=x y' -b ' k1=v1; '",
+				want: received{method: "POST", uri: "/b", body: "a=1&b=x+y", auth: ":", cookie: "k1=v1", contentType: "application/x-www-form-urlencoded"}},
+			{name: "003", command: `curl -XPATCH "` + srv.URL + `/c" --json $'{"a":"\'x\'\n"}' -u u:p`,
+				want: received{method: "PATCH", uri: "/c", body: "{\"a\":\"'x'\n\"}", auth: "u:p", contentType: "application/json"}},
+			{name: "004", command: `curl -G ` + srv.URL + `/d?x=1 -d y=2`,
+				want: received{method: "GET", uri: "/d?x=1&y=2", auth: ":"}},
+			{name: "005", command: `curl ` + srv.URL + ` --unknown`, wantErr: true},
+			{name: "006", command: `wget ` + srv.URL, wantErr: true},
+			{name: "007", command: `curl '` + srv.URL, wantErr: true},
+			{name: "008", command: `/usr/bin/curl ` + srv.URL + `/e`, want: received{method: "GET", uri: "/e", auth: ":"}},
+			{name: "009", command: `'C:\Windows\System32\curl.exe' ` + srv.URL + `/f`, want: received{method: "GET", uri: "/f", auth: ":"}},
+			{name: "010", command: `curl.exe -X GET ` + srv.URL + `/g -d a=1`,
+				want: received{method: "GET", uri: "/g", body: "a=1", auth: ":", contentType: "application/x-www-form-urlencoded"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got = received{}
+				cc, err := utils.NewCurlFromCommand(tt.command)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("NewCurlFromCommand() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if err = cc.Do(); err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("received = %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+
+		// 上传文件
+		cc, err := utils.NewCurlFromCommand(`curl ` + srv.URL + `/upload -F 'name=a' -F 'file=@` + file + `;type=text/plain'`)
+		if err != nil {
+			t.Fatalf("NewCurlFromCommand() error = %v", err)
+		}
+		if err = cc.Do(); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if got.method != "POST" || !strings.HasPrefix(got.contentType, "multipart/form-data") || !strings.Contains(got.body, "file content") {
+			t.Errorf("received = %+v", got)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// WithCurlRequestCompression 设置请求体压缩方式: gzip, deflate; 为空不压缩
+func WithCurlRequestCompression(encoding string) CurlOption {
+	return func(c *Curl) {
+		c.SetRequestCompression(encoding)
+	}
+}
+
+// SetRequestCompression 设置请求体压缩方式: gzip, deflate; 为空不压缩
+//
+//	请求体压缩后设置请求头 Content-Encoding, 日志及 dump 记录压缩前的内容
+//	请求体边读取边压缩, 不缓存到内存, 以分块传输编码(chunked)发送
+//	SignMiddleware 对压缩前的请求体签名, SignVerifier 按 Content-Encoding 解压后验证签名
+func (c *Curl) SetRequestCompression(encoding string) *Curl {
+	c.compression = strings.ToLower(strings.TrimSpace(encoding))
+	return c
+}
+
+// encodingMiddleware 压缩请求体, 并按响应头 Content-Encoding 解压响应体的中间件
+//
+//	位于默认日志内层, 日志、dump 及 AfterBody 等处理方法均使用未压缩的内容
+func encodingMiddleware(compression string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if compression != "" && req.Body != nil && req.Body != http.NoBody {
+				r := new(http.Request)
+				*r = *req
+				r.Header = req.Header.Clone()
+				if err := compressBody(r, compression); err != nil {
+					return nil, errors.Wrap(err)
+				}
+				req = r
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				// 内层返回错误时请求体可能未交给 Transport(如 BeforeClient 返回错误), 关闭压缩请求体使写入数据的协程退出
+				if req.Body != nil && req.Body != http.NoBody {
+					_ = req.Body.Close()
+				}
+				return resp, err
+			}
+			decompressBody(resp)
+			return resp, nil
+		})
+	}
+}
+
+// compressBody 使用 io.Pipe 流式压缩请求体
+func compressBody(req *http.Request, encoding string) error {
+	var newWriter func(w io.Writer) io.WriteCloser
+	switch encoding {
+	case "gzip":
+		newWriter = func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	case "deflate":
+		newWriter = func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	default:
+		_ = req.Body.Close()
+		return errors.Errorf("不支持的请求体压缩方式: %s", encoding)
+	}
+
+	// 重试时压缩重新生成的请求体
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			return compressReader(body, newWriter), nil
+		}
+	}
+	req.Body = compressReader(req.Body, newWriter)
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	req.Header.Set("Content-Encoding", encoding)
+	return nil
+}
+
+// compressReader 读取时压缩 body 的内容
+//
+//	关闭返回的 Reader 时同时关闭 body, 写入数据的协程随之退出; 未交给 Transport 时需由调用方关闭
+func compressReader(body io.ReadCloser, newWriter func(w io.Writer) io.WriteCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w := newWriter(pw)
+		_, err := io.Copy(w, body)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		_ = body.Close()
+		pw.CloseWithError(err)
+	}()
+	return &compressedBody{PipeReader: pr, body: body}
+}
+
+// compressedBody 压缩后的请求体
+type compressedBody struct {
+	*io.PipeReader
+	body io.ReadCloser
+}
+
+// Close 实现 io.Closer 接口: 关闭管道及原请求体, 写入数据的协程阻塞在读取或写入时均可退出
+func (b *compressedBody) Close() error {
+	_ = b.PipeReader.Close()
+	return b.body.Close()
+}
+
+// decompressBody 按响应头 Content-Encoding 解压响应体: gzip, deflate
+//
+//	Transport 已自动解压(未设置 DisableCompression 且未手动设置请求头 Accept-Encoding)时响应头无 Content-Encoding
+func decompressBody(resp *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	resp.Body = &decompressReader{body: resp.Body, encoding: encoding}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressReader 首次读取时创建解压 Reader, 空响应体(如 HEAD 请求)不报错
+type decompressReader struct {
+	body     io.ReadCloser
+	encoding string
+	r        io.Reader
+	err      error
+}
+
+// Read 实现 io.Reader 接口
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.reader()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+// reader 创建解压 Reader
+func (d *decompressReader) reader() (io.Reader, error) {
+	br := bufio.NewReader(d.body)
+	if _, err := br.Peek(1); err != nil {
+		// 空响应体
+		return nil, err
+	}
+
+	if d.encoding != "deflate" {
+		return gzip.NewReader(br)
+	}
+
+	// deflate 应为 zlib 格式, 兼容部分服务端返回的原始 deflate 格式
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// Close 实现 io.Closer 接口
+func (d *decompressReader) Close() error {
+	if c, ok := d.r.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return d.body.Close()
+}
+
+// decodeBody 按 Content-Encoding 解压内容: gzip, deflate; 为空或 identity 时不处理
+//
+//	limit 解压后的内容长度上限: <=0 不限制
+func decodeBody(encoding string, body []byte, limit int64) ([]byte, error) {
+	switch encoding = strings.ToLower(strings.TrimSpace(encoding)); encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip", "deflate":
+	default:
+		return nil, errors.Errorf("不支持的 Content-Encoding: %s", encoding)
+	}
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	var r io.ReadCloser = &decompressReader{body: io.NopCloser(bytes.NewReader(body)), encoding: encoding}
+	if limit > 0 {
+		r = http.MaxBytesReader(nil, r, limit)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return b, nil
+}
@@ -0,0 +1,214 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCurlCompression(t *testing.T) {
+	var flaky atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 连接断开后重试
+		if r.URL.Path == "/flaky" && flaky.Add(1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		// 解压请求体
+		var body io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			body, _ = gzip.NewReader(r.Body)
+		case "deflate":
+			body, _ = zlib.NewReader(r.Body)
+		}
+		b, _ := io.ReadAll(body)
+		reply := "echo:" + r.Header.Get("Content-Encoding") + ":" + string(b)
+
+		// 压缩响应体
+		var buf bytes.Buffer
+		switch encoding := r.URL.Query().Get("encoding"); encoding {
+		case "gzip":
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte(reply))
+			zw.Close()
+			w.Header().Set("Content-Encoding", encoding)
+		case "deflate":
+			zw := zlib.NewWriter(&buf)
+			zw.Write([]byte(reply))
+			zw.Close()
+			w.Header().Set("Content-Encoding", encoding)
+		case "raw-deflate":
+			zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			zw.Write([]byte(reply))
+			zw.Close()
+			w.Header().Set("Content-Encoding", "deflate")
+		default:
+			buf.WriteString(reply)
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Encoding", "gzip")
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	payload := strings.Repeat(`{"id":1,"name":"bulk"}`, 100)
+	tests := []struct {
+		name        string
+		compression string
+		path        string
+		want        string
+		wantErr     bool
+	}{
+		{name: "001", compression: "gzip", path: "/?encoding=gzip", want: "echo:gzip:" + payload},
+		{name: "002", compression: "deflate", path: "/?encoding=deflate", want: "echo:deflate:" + payload},
+		{name: "003", compression: "", path: "/?encoding=raw-deflate", want: "echo::" + payload},
+		{name: "004", compression: "GZIP", path: "/flaky?encoding=gzip", want: "echo:gzip:" + payload},
+		{name: "005", compression: "br", path: "/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			logger := &bufLogger{}
+			err := utils.NewCurl(
+				utils.WithCurlRequestCompression(tt.compression),
+				// 关闭 Transport 自动解压
+				utils.WithCurlTransport(&http.Transport{DisableCompression: true}),
+				utils.WithCurlLogger(logger),
+				utils.WithCurlDefLogOutput(true),
+				utils.WithCurlDump(true),
+				utils.WithCurlDumpBodyLimit(int64(len(payload)+100)),
+				utils.WithCurlBodyBytes([]byte(payload)),
+			).AfterBody(func(body []byte) error {
+				got = string(body)
+				return nil
+			}).Post(srv.URL + tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("AfterBody() = %.50s, want %.50s", got, tt.want)
+			}
+
+			// dump 记录未压缩的内容
+			if out := logger.String(); !strings.Contains(out, payload) || !strings.Contains(out, tt.want) {
+				t.Errorf("dump does not contain decoded content: %.300s", out)
+			}
+		})
+	}
+
+	// 签名使用压缩前的请求体, 服务端解压后验证
+	t.Run("Sign", func(t *testing.T) {
+		key := []byte("secret")
+		verifier := utils.NewSignVerifier(utils.HMACVerifier(key, crypto.SHA256, hex.DecodeString))
+		var received atomic.Value
+		signSrv := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 请求体按原始内容传递给后续处理
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip.NewReader() error = %v", err)
+				return
+			}
+			b, _ := io.ReadAll(zr)
+			received.Store(fmt.Sprintf("%d:%s", r.ContentLength, b))
+			utils.Json(w).Success(10000, "ok")
+		})))
+		defer signSrv.Close()
+
+		for _, body := range []io.Reader{strings.NewReader(payload), io.MultiReader(strings.NewReader(payload))} {
+			err := utils.NewCurl(
+				utils.WithCurlRequestCompression("gzip"),
+				utils.WithCurlMiddleware(utils.SignMiddleware(utils.HMACSigner(key, crypto.SHA256, hex.EncodeToString), utils.WithSignBody(true))),
+			).Send(http.MethodPost, signSrv.URL, body)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			// 流式压缩: 分块传输编码, ContentLength 未知
+			if got := received.Load(); got != "-1:"+payload {
+				t.Errorf("received = %.50v, want -1:%.40s", got, payload)
+			}
+		}
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		curl := utils.NewCurl(utils.WithCurlTransport(&http.Transport{DisableCompression: true}))
+		if err := curl.Head(srv.URL); err != nil {
+			t.Errorf("Head() error = %v", err)
+		}
+	})
+}
+
+func TestCompressionClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		curl func() *utils.Curl
+	}{
+		// BeforeClient 返回错误: 压缩请求体未交给 Transport
+		{name: "001", curl: func() *utils.Curl {
+			return utils.NewCurl().BeforeClient(func(*http.Client) error {
+				return io.ErrUnexpectedEOF
+			})
+		}},
+		// 熔断
+		{name: "002", curl: func() *utils.Curl {
+			breaker := utils.NewCircuitBreaker(utils.WithBreakerFailureRatio(0.5, 1), utils.WithBreakerCoolDown(time.Hour))
+			return utils.NewCurl(
+				utils.WithCurlCircuitBreaker(breaker),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))),
+			)
+		}},
+		// 重试
+		{name: "003", curl: func() *utils.Curl {
+			return utils.NewCurl(utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))))
+		}},
+	}
+	data := bytes.Repeat([]byte("0123456789"), 1<<14)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			for range 20 {
+				curl := tt.curl().SetRequestCompression("gzip")
+				if err := curl.Send(http.MethodPost, srv.URL, bytes.NewReader(data)); err == nil {
+					t.Fatalf("Send() error = nil")
+				}
+			}
+
+			// 压缩数据的协程退出
+			after := runtime.NumGoroutine()
+			for i := 0; i < 100 && after > before+5; i++ {
+				time.Sleep(10 * time.Millisecond)
+				after = runtime.NumGoroutine()
+			}
+			if after > before+5 {
+				t.Errorf("goroutines = %d, want <= %d", after, before+5)
+			}
+		})
+	}
+}
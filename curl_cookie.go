@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// CookieJar 可持久化的 http.CookieJar, 会话模式下自动保存响应中的 Cookie 并在后续请求中发送
+//
+//	Cookie 的 Domain, Path, Expires, Secure 等规则由 net/http/cookiejar 处理
+//	未设置 PublicSuffixList, 同一注册域下的子域名之间可以共享 Cookie
+type CookieJar struct {
+	mu sync.Mutex
+
+	jar *cookiejar.Jar
+
+	// 已保存的 Cookie, 用于持久化: key 为 domain;path;name
+	entries map[string]*cookieEntry
+}
+
+// cookieEntry 持久化的 Cookie
+type cookieEntry struct {
+	URL      string        `json:"url"` // 设置 Cookie 的请求地址
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  time.Time     `json:"expires,omitempty"` // 零值为会话 Cookie
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// NewCookieJar 实例化CookieJar
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(nil) // 参数为nil时不会返回错误
+	return &CookieJar{jar: jar, entries: make(map[string]*cookieEntry)}
+}
+
+// LoadCookieJar 从JSON文件加载 CookieJar, 文件不存在时返回空的 CookieJar
+func LoadCookieJar(file string) (*CookieJar, error) {
+	j := NewCookieJar()
+	if err := j.Load(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrap(err)
+	}
+	return j, nil
+}
+
+// SetCookies 实现 http.CookieJar 接口
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.setCookies(u, cookies, time.Now())
+}
+
+// setCookies 保存 Cookie, 调用方需持有锁
+func (j *CookieJar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
+	j.jar.SetCookies(u, cookies)
+
+	for _, cookie := range cookies {
+		e := &cookieEntry{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   strings.TrimPrefix(strings.ToLower(cookie.Domain), "."),
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}
+		// 与请求域名不匹配的 Cookie 会被 cookiejar 忽略
+		if host := u.Hostname(); e.Domain != "" && host != e.Domain && !strings.HasSuffix(host, "."+e.Domain) {
+			continue
+		}
+		if e.Path == "" || e.Path[0] != '/' {
+			e.Path = defaultCookiePath(u.Path)
+		}
+
+		// MaxAge 优先于 Expires, 转换为绝对过期时间
+		if cookie.MaxAge > 0 {
+			e.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+
+		key := Ternary(e.Domain == "", u.Hostname(), e.Domain) + ";" + e.Path + ";" + e.Name
+		if cookie.MaxAge < 0 || (!e.Expires.IsZero() && !e.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = e
+	}
+}
+
+// Cookies 实现 http.CookieJar 接口
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	jar := j.jar
+	j.mu.Unlock()
+	return jar.Cookies(u)
+}
+
+// Clear 清空所有 Cookie
+func (j *CookieJar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar, _ = cookiejar.New(nil)
+	clear(j.entries)
+}
+
+// Len 获取未过期的 Cookie 数量
+func (j *CookieJar) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n, now := 0, time.Now()
+	for _, e := range j.entries {
+		if e.Expires.IsZero() || e.Expires.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// MarshalJSON 实现 json.Marshaler 接口, 不包含已过期的 Cookie
+func (j *CookieJar) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	list, now := make([]*cookieEntry, 0, len(j.entries)), time.Now()
+	for _, e := range j.entries {
+		if e.Expires.IsZero() || e.Expires.After(now) {
+			list = append(list, e)
+		}
+	}
+	return json.Marshal(list)
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口, 追加到已有的 Cookie
+func (j *CookieJar) UnmarshalJSON(data []byte) error {
+	var list []*cookieEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.Wrap(err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, e := range list {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		j.setCookies(u, []*http.Cookie{{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			Path:     e.Path,
+			Expires:  e.Expires,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: e.SameSite,
+		}}, now)
+	}
+	return nil
+}
+
+// Save 保存到JSON文件, 文件权限 0600
+func (j *CookieJar) Save(file string) error {
+	b, err := j.MarshalJSON()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(os.WriteFile(file, b, 0600))
+}
+
+// Load 从JSON文件加载, 追加到已有的 Cookie
+func (j *CookieJar) Load(file string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(j.UnmarshalJSON(b))
+}
+
+// defaultCookiePath Cookie 默认路径: 请求路径最后一个 "/" 之前的部分
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
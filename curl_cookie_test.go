@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCookieJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", MaxAge: 3600, HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "admin", Value: "1", Path: "/admin"})
+			http.SetCookie(w, &http.Cookie{Name: "other", Value: "1", Domain: "example.com"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+		}
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		utils.Json(w).Success(10000, names)
+	}))
+	defer srv.Close()
+
+	cookies := func(curl *utils.Curl, path string) []string {
+		var got struct {
+			Data []string `json:"data"`
+		}
+		if err := curl.GetJSON(srv.URL+path, &got); err != nil {
+			t.Fatalf("GetJSON() error = %v", err)
+		}
+		return got.Data
+	}
+
+	jar := utils.NewCookieJar()
+	curl := utils.NewCurl(utils.WithCurlCookieJar(jar))
+	if got := cookies(curl, "/login"); len(got) != 0 {
+		t.Errorf("/login cookies = %v, want []", got)
+	}
+	if got := cookies(curl, "/me"); len(got) != 1 || got[0] != "session=abc" {
+		t.Errorf("/me cookies = %v, want [session=abc]", got)
+	}
+	if got := cookies(curl, "/admin/index"); len(got) != 2 {
+		t.Errorf("/admin/index cookies = %v, want 2 cookies", got)
+	}
+	if jar.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", jar.Len())
+	}
+
+	// 持久化并在新的 CookieJar 中加载
+	file := filepath.Join(t.TempDir(), "session.json")
+	if err := jar.Save(file); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := utils.LoadCookieJar(file)
+	if err != nil {
+		t.Fatalf("LoadCookieJar() error = %v", err)
+	}
+	curl = utils.NewCurl(utils.WithCurlCookieJar(loaded))
+	if got := cookies(curl, "/me"); len(got) != 1 || got[0] != "session=abc" {
+		t.Errorf("loaded /me cookies = %v, want [session=abc]", got)
+	}
+
+	// 删除 Cookie
+	cookies(curl, "/logout")
+	if got := cookies(curl, "/me"); len(got) != 0 {
+		t.Errorf("/me cookies after logout = %v, want []", got)
+	}
+	if loaded.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", loaded.Len())
+	}
+
+	// 文件不存在
+	if jar, err := utils.LoadCookieJar(filepath.Join(t.TempDir(), "none.json")); err != nil || jar.Len() != 0 {
+		t.Errorf("LoadCookieJar() = %v, %v", jar.Len(), err)
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// DecodeError 响应体解码错误
+type DecodeError struct {
+	StatusCode  int    // 响应状态码
+	ContentType string // 响应头 Content-Type
+	Body        []byte // 响应体预览内容
+	Truncated   bool   // 响应体预览内容是否被截断
+	Err         error  // 解码错误
+}
+
+// Error 实现Error接口
+func (e *DecodeError) Error() string {
+	b := fmt.Sprintf("decode response body error: statusCode=%d, contentType=%s, err=%v, body=%s", e.StatusCode, e.ContentType, e.Err, e.Body)
+	if e.Truncated {
+		b += "...[truncated]"
+	}
+	return b
+}
+
+// Unwrap 实现Unwrap接口, 返回解码错误
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newDecodeError 创建解码错误, 响应体按 limit 截取预览内容
+func newDecodeError(err error, body []byte, limit int64) *DecodeError {
+	e := &DecodeError{Err: err}
+	if limit > 0 {
+		e.Body, e.Truncated, _ = readBodyPreview(bytes.NewReader(body), limit)
+	}
+	return e
+}
+
+// fillDecodeError 补全解码错误中的响应信息
+func fillDecodeError(err error, resp *http.Response) error {
+	var de *DecodeError
+	if resp != nil && errors.As(err, &de) && de.StatusCode == 0 {
+		de.StatusCode = resp.StatusCode
+		de.ContentType = resp.Header.Get("Content-Type")
+	}
+	return err
+}
+
+// decodeJSON 使用 Configure(WithJSON(...)) 设置的解码方法解码JSON
+func decodeJSON(body []byte, v any) error {
+	return Unmarshal(body, v)
+}
+
+// AfterBodyJSON 请求后将 Response.Body 按JSON解码为T类型, 并交给f处理
+//
+//	解码使用 Configure(WithJSON(...)) 设置的解码方法, 解码失败返回 *DecodeError
+func AfterBodyJSON[T any](c *Curl, f func(v *T) error) *Curl {
+	return c.AfterBody(afterBodyDecode(c, decodeJSON, f))
+}
+
+// AfterBodyXML 请求后将 Response.Body 按XML解码为T类型, 并交给f处理
+//
+//	解码失败返回 *DecodeError
+func AfterBodyXML[T any](c *Curl, f func(v *T) error) *Curl {
+	return c.AfterBody(afterBodyDecode(c, xml.Unmarshal, f))
+}
+
+// afterBodyDecode 生成解码 Response.Body 的处理方法
+func afterBodyDecode[T any](c *Curl, decode Decode, f func(v *T) error) func(body []byte) error {
+	return func(body []byte) error {
+		v := new(T)
+		if err := decode(body, v); err != nil {
+			return newDecodeError(err, body, c.dumpBodyLimit)
+		}
+		if f == nil {
+			return nil
+		}
+		return f(v)
+	}
+}
+
+// GetJSON 发起 GET 请求, 并将JSON响应解码到 out
+func (c *Curl) GetJSON(url string, out any) error {
+	return c.GetJSONContext(context.Background(), url, out)
+}
+
+// GetJSONContext 携带 context 发起 GET 请求, 并将JSON响应解码到 out
+func (c *Curl) GetJSONContext(ctx context.Context, url string, out any) (err error) {
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return c.sendDecode(ctx, http.MethodGet, url, c.body, http.Header{"Accept": {"application/json"}}, out, decodeJSON)
+}
+
+// PostJSON 将 in 按JSON编码作为请求体发起 POST 请求, 并将JSON响应解码到 out
+//
+//	in 为nil时不发送请求体; out 为nil时不解码响应
+func (c *Curl) PostJSON(url string, in, out any) error {
+	return c.PostJSONContext(context.Background(), url, in, out)
+}
+
+// PostJSONContext 携带 context 将 in 按JSON编码作为请求体发起 POST 请求, 并将JSON响应解码到 out
+func (c *Curl) PostJSONContext(ctx context.Context, url string, in, out any) error {
+	return c.SendJSONContext(ctx, http.MethodPost, url, in, out)
+}
+
+// SendJSONContext 携带 context 将 in 按JSON编码作为请求体发起请求, 并将JSON响应解码到 out
+//
+//	编解码使用 Configure(WithJSON(...)) 设置的方法, 本次请求自动设置请求头 Content-Type 和 Accept, 不修改 Curl 的请求头
+//	解码失败返回 *DecodeError
+func (c *Curl) SendJSONContext(ctx context.Context, method, url string, in, out any) (err error) {
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := Marshal(in)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}, "Accept": {"application/json"}}
+	return c.sendDecode(ctx, method, url, body, header, out, decodeJSON)
+}
+
+// GetXML 发起 GET 请求, 并将XML响应解码到 out
+func (c *Curl) GetXML(url string, out any) error {
+	return c.GetXMLContext(context.Background(), url, out)
+}
+
+// GetXMLContext 携带 context 发起 GET 请求, 并将XML响应解码到 out
+func (c *Curl) GetXMLContext(ctx context.Context, url string, out any) (err error) {
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return c.sendDecode(ctx, http.MethodGet, url, c.body, http.Header{"Accept": {"application/xml"}}, out, xml.Unmarshal)
+}
+
+// PostXML 将 in 按XML编码作为请求体发起 POST 请求, 并将XML响应解码到 out
+//
+//	in 为nil时不发送请求体; out 为nil时不解码响应
+func (c *Curl) PostXML(url string, in, out any) error {
+	return c.PostXMLContext(context.Background(), url, in, out)
+}
+
+// PostXMLContext 携带 context 将 in 按XML编码作为请求体发起 POST 请求, 并将XML响应解码到 out
+func (c *Curl) PostXMLContext(ctx context.Context, url string, in, out any) (err error) {
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := xml.Marshal(in)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	header := http.Header{"Content-Type": {"application/xml"}, "Accept": {"application/xml"}}
+	return c.sendDecode(ctx, http.MethodPost, url, body, header, out, xml.Unmarshal)
+}
+
+// sendDecode 发起请求, 并使用 decode 将响应解码到 out
+//
+//	header 本次请求设置的请求头(如 Content-Type, Accept), 不修改 Curl 的请求头
+func (c *Curl) sendDecode(ctx context.Context, method, url string, body io.Reader, header http.Header, out any, decode Decode) error {
+	hooks := sendHooks{header: header}
+	if out != nil {
+		hooks.body = func(resp *http.Response, body []byte) error {
+			// 无响应内容
+			if len(body) == 0 {
+				return nil
+			}
+			if err := decode(body, out); err != nil {
+				return fillDecodeError(newDecodeError(err, body, c.dumpBodyLimit), resp)
+			}
+			return nil
+		}
+	}
+	return c.send(ctx, method, url, body, hooks)
+}
@@ -0,0 +1,119 @@
+package utils_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+type xmlResp struct {
+	XMLName xml.Name `xml:"response"`
+	Data    User     `xml:"data"`
+}
+
+func TestCurlDecode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			user := User{}
+			if r.Method == http.MethodPost {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("Content-Type = %v", r.Header.Get("Content-Type"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				if err := utils.Unmarshal(body, &user); err != nil {
+					t.Errorf("Unmarshal() error = %v", err)
+				}
+			}
+			user.Name = "Andy"
+			utils.Json(w).Success(10000, user)
+		case "/xml":
+			utils.View(w).Xml(xmlResp{Data: User{Name: "Lisa"}})
+		default:
+			_, _ = w.Write([]byte("not json"))
+		}
+	}))
+	defer srv.Close()
+
+	curl := utils.NewCurl()
+
+	t.Run("GetJSON", func(t *testing.T) {
+		res := &RespBody[User]{}
+		if err := curl.GetJSON(srv.URL+"/json", res); err != nil {
+			t.Fatalf("GetJSON() error = %v", err)
+		}
+		if res.Data.Name != "Andy" {
+			t.Errorf("GetJSON() Name = %v, want %v", res.Data.Name, "Andy")
+		}
+	})
+
+	t.Run("PostJSON", func(t *testing.T) {
+		res := &RespBody[User]{}
+		if err := curl.PostJSON(srv.URL+"/json", User{Age: 18}, res); err != nil {
+			t.Fatalf("PostJSON() error = %v", err)
+		}
+		if res.Data.Age != 18 {
+			t.Errorf("PostJSON() Age = %v, want %v", res.Data.Age, 18)
+		}
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		// 请求头只作用于本次请求, 不修改 Curl 的请求头
+		c := utils.NewCurl(utils.WithCurlContentType("text/plain"))
+		if err := c.PostJSON(srv.URL+"/json", User{}, &RespBody[User]{}); err != nil {
+			t.Fatalf("PostJSON() error = %v", err)
+		}
+		if err := c.GetXML(srv.URL+"/xml", &xmlResp{}); err != nil {
+			t.Fatalf("GetXML() error = %v", err)
+		}
+		if h := c.GetHeader(); h.Get("Content-Type") != "text/plain" || h.Get("Accept") != "" {
+			t.Errorf("GetHeader() = %v, want Content-Type text/plain and no Accept", h)
+		}
+	})
+
+	t.Run("GetXML", func(t *testing.T) {
+		res := &xmlResp{}
+		if err := curl.GetXML(srv.URL+"/xml", res); err != nil {
+			t.Fatalf("GetXML() error = %v", err)
+		}
+		if res.Data.Name != "Lisa" {
+			t.Errorf("GetXML() Name = %v, want %v", res.Data.Name, "Lisa")
+		}
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		err := curl.GetJSON(srv.URL+"/text", &RespBody[User]{})
+		var de *utils.DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("GetJSON() error = %v, want *DecodeError", err)
+		}
+		if de.StatusCode != http.StatusOK || string(de.Body) != "not json" {
+			t.Errorf("DecodeError = %+v", de)
+		}
+	})
+
+	t.Run("AfterBodyJSON", func(t *testing.T) {
+		var name string
+		c := utils.AfterBodyJSON(utils.NewCurl(), func(v *RespBody[User]) error {
+			name = v.Data.Name
+			return nil
+		})
+		if err := c.Get(srv.URL + "/json"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if name != "Andy" {
+			t.Errorf("AfterBodyJSON() Name = %v, want %v", name, "Andy")
+		}
+
+		err := c.Get(srv.URL + "/text")
+		var de *utils.DecodeError
+		if !errors.As(err, &de) || de.StatusCode != http.StatusOK {
+			t.Errorf("AfterBodyJSON() error = %v, want *DecodeError", err)
+		}
+	})
+}
@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// DownloadProgress 下载进度
+//   - written 已下载字节数(含断点续传前已下载部分)
+//   - total 文件总字节数: 未知时为 -1
+//   - rate 下载速率(字节/秒)
+type DownloadProgress func(written, total int64, rate float64)
+
+// DownloadOption 下载配置项
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	resume   bool
+	perm     os.FileMode
+	progress DownloadProgress
+	interval time.Duration
+	hash     crypto.Hash
+	checksum string
+}
+
+// WithDownloadResume 设置是否断点续传, 默认: true
+//
+//	临时文件(目标文件名 + ".download")存在时, 使用请求头 Range 从已下载位置继续下载
+func WithDownloadResume(resume bool) DownloadOption {
+	return func(o *downloadOptions) {
+		o.resume = resume
+	}
+}
+
+// WithDownloadPerm 设置文件权限, 默认: 0644
+func WithDownloadPerm(perm os.FileMode) DownloadOption {
+	return func(o *downloadOptions) {
+		o.perm = perm
+	}
+}
+
+// WithDownloadProgress 设置下载进度回调方法
+//
+//	interval 回调间隔, 默认: 200毫秒; 下载完成时总会回调一次
+func WithDownloadProgress(progress DownloadProgress, interval ...time.Duration) DownloadOption {
+	return func(o *downloadOptions) {
+		o.progress = progress
+		if len(interval) > 0 && interval[0] > 0 {
+			o.interval = interval[0]
+		}
+	}
+}
+
+// WithDownloadChecksum 设置下载完成后校验文件摘要
+//
+//	hash 摘要算法: crypto.MD5, crypto.SHA1, crypto.SHA256, crypto.SHA512
+//	checksum 十六进制摘要值, 与 Md5, Sha1, Sha256, Sha512 的结果格式一致
+func WithDownloadChecksum(hash crypto.Hash, checksum string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.hash = hash
+		o.checksum = strings.ToLower(strings.TrimSpace(checksum))
+	}
+}
+
+// Download 下载文件
+//
+//	url 下载地址
+//	destPath 文件保存路径: 先写入临时文件(destPath + ".download"), 下载并校验完成后重命名为 destPath
+func (c *Curl) Download(url, destPath string, opts ...DownloadOption) error {
+	return c.DownloadContext(context.Background(), url, destPath, opts...)
+}
+
+// DownloadContext 携带 context 下载文件, 响应体直接写入文件不缓存到内存
+//
+//	Curl 超时时间只限制收到响应头之前的时间, 读取响应体由 ctx 控制
+//	url 下载地址
+//	destPath 文件保存路径: 先写入临时文件(destPath + ".download"), 下载并校验完成后重命名为 destPath
+func (c *Curl) DownloadContext(ctx context.Context, url, destPath string, opts ...DownloadOption) (err error) {
+	cfg := downloadOptions{
+		resume:   true,
+		perm:     0644,
+		interval: 200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	if cfg.checksum != "" && !cfg.hash.Available() {
+		return errors.Errorf("不支持的摘要算法: %v", cfg.hash)
+	}
+
+	rawURL := url
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 已下载部分
+	tmpPath := destPath + ".download"
+	var offset int64
+	if cfg.resume {
+		if size, err := Size(tmpPath); err == nil {
+			offset = size
+		}
+	}
+
+	// 断点续传: 只设置本次请求的请求头; 临时文件已下载完整时服务端返回416
+	var (
+		header     http.Header
+		statusCode = []int{http.StatusPartialContent}
+	)
+	if offset > 0 {
+		header = http.Header{"Range": {"bytes=" + strconv.FormatInt(offset, 10) + "-"}}
+		statusCode = append(statusCode, http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	parent := ctx
+	t := c.headerTimer(ctx)
+	defer t.stop()
+	ctx = t.ctx
+
+	var restart bool
+	hooks := sendHooks{
+		statusCode: statusCode,
+		stream:     true,
+		noTimeout:  true,
+		header:     header,
+		response: func(resp *http.Response) (bool, error) {
+			if err := t.received(); err != nil {
+				return true, errors.Wrap(err)
+			}
+
+			switch {
+			case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+				// Content-Range: bytes */size, 文件大小与临时文件一致时下载已完成, 否则重新下载
+				if _, size := parseContentRange(resp.Header.Get("Content-Range")); size != offset {
+					restart = true
+					return true, nil
+				}
+				return true, completeDownload(tmpPath, offset, &cfg)
+			case resp.StatusCode != http.StatusPartialContent:
+				// 服务端不支持断点续传, 重新下载
+				offset = 0
+			default:
+				if start, _ := parseContentRange(resp.Header.Get("Content-Range")); start != offset {
+					return true, errors.Errorf("Content-Range 起始位置错误: %s, 已下载: %d", resp.Header.Get("Content-Range"), offset)
+				}
+			}
+
+			return true, c.saveDownload(ctx, resp, tmpPath, offset, &cfg)
+		},
+	}
+
+	if err = t.err(c.send(ctx, http.MethodGet, url, nil, hooks)); err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 临时文件与服务端文件不一致: 删除临时文件后重新下载
+	if restart {
+		if err = os.Remove(tmpPath); err != nil {
+			return errors.Wrap(err)
+		}
+		return errors.Wrap(c.DownloadContext(parent, rawURL, destPath, opts...))
+	}
+
+	return errors.Wrap(os.Rename(tmpPath, destPath))
+}
+
+// saveDownload 将响应体写入临时文件并校验摘要
+func (c *Curl) saveDownload(ctx context.Context, resp *http.Response, tmpPath string, offset int64, cfg *downloadOptions) error {
+	// 文件总大小
+	total := int64(-1)
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, size := parseContentRange(resp.Header.Get("Content-Range")); size >= 0 {
+			total = size
+		} else if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+	} else if resp.ContentLength >= 0 {
+		total = resp.ContentLength
+	}
+
+	// 摘要
+	var h hash.Hash
+	if cfg.checksum != "" {
+		h = cfg.hash.New()
+		if offset > 0 {
+			if err := hashFile(h, tmpPath); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+	}
+
+	w := &progressWriter{
+		written:  offset,
+		total:    total,
+		start:    time.Now(),
+		progress: cfg.progress,
+		interval: cfg.interval,
+	}
+	if err := writeDownload(ctx, resp.Body, tmpPath, offset > 0, h, w, cfg.perm); err != nil {
+		return errors.Wrap(err)
+	}
+	w.report(true)
+
+	// 校验摘要
+	if h != nil {
+		if err := checkDownload(h, tmpPath, cfg.checksum); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
+	if total >= 0 && w.written != total {
+		return errors.Errorf("文件下载不完整: got %d, want %d", w.written, total)
+	}
+	return nil
+}
+
+// writeDownload 将响应体写入临时文件, 写入完成后同步到磁盘并关闭文件
+func writeDownload(ctx context.Context, body io.ReadCloser, tmpPath string, isAppend bool, h hash.Hash, w *progressWriter, perm os.FileMode) (err error) {
+	f, err := NewWrite(tmpPath, WithWriteAppend(isAppend), WithWritePerm(perm))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = errors.Wrap(cerr)
+		}
+	}()
+
+	var dst io.Writer = f
+	if h != nil {
+		dst = io.MultiWriter(f, h)
+	}
+
+	if _, err = io.Copy(io.MultiWriter(dst, w), ctxReadCloser(ctx, body)); err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(f.File.Sync())
+}
+
+// completeDownload 临时文件已下载完整(服务端返回416), 校验摘要
+func completeDownload(tmpPath string, size int64, cfg *downloadOptions) error {
+	if cfg.checksum != "" {
+		h := cfg.hash.New()
+		if err := hashFile(h, tmpPath); err != nil {
+			return errors.Wrap(err)
+		}
+		if err := checkDownload(h, tmpPath, cfg.checksum); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	if cfg.progress != nil {
+		cfg.progress(size, size, 0)
+	}
+	return nil
+}
+
+// checkDownload 校验文件摘要, 校验失败时删除临时文件
+func checkDownload(h hash.Hash, tmpPath, checksum string) error {
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != checksum {
+		_ = os.Remove(tmpPath)
+		return errors.Errorf("文件摘要校验失败: got %s, want %s", sum, checksum)
+	}
+	return nil
+}
+
+// hashFile 计算已下载部分的摘要
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	return errors.Wrap(err)
+}
+
+// parseContentRange 解析响应头 Content-Range: bytes start-end/size
+//
+//	解析失败 start 返回 -1; size 未知(*)时返回 -1
+func parseContentRange(contentRange string) (start, size int64) {
+	start, size = -1, -1
+	v, ok := strings.CutPrefix(strings.TrimSpace(contentRange), "bytes ")
+	if !ok {
+		return
+	}
+	r, total, ok := strings.Cut(v, "/")
+	if !ok {
+		return
+	}
+	if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+		size = n
+	}
+	first, _, ok := strings.Cut(r, "-")
+	if !ok {
+		return
+	}
+	if n, err := strconv.ParseInt(first, 10, 64); err == nil {
+		start = n
+	}
+	return
+}
+
+// progressWriter 统计写入字节数并回调下载进度
+type progressWriter struct {
+	written, total int64
+	n              int64 // 本次下载字节数
+	start, last    time.Time
+	progress       DownloadProgress
+	interval       time.Duration
+}
+
+// Write 实现 io.Writer 接口
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	w.n += int64(len(p))
+	w.report(false)
+	return len(p), nil
+}
+
+// report 回调下载进度, done 为 true 时总会回调
+func (w *progressWriter) report(done bool) {
+	if w.progress == nil {
+		return
+	}
+	now := time.Now()
+	if !done && now.Sub(w.last) < w.interval {
+		return
+	}
+	w.last = now
+
+	var rate float64
+	if elapsed := now.Sub(w.start).Seconds(); elapsed > 0 {
+		rate = float64(w.n) / elapsed
+	}
+	w.progress(w.written, w.total, rate)
+}
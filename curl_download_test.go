@@ -0,0 +1,144 @@
+package utils_test
+
+import (
+	"crypto"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "file.txt", time.Now(), strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	type args struct {
+		partial  string // 已下载部分
+		checksum string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantRange string
+		wantErr   bool
+	}{
+		{name: "001", args: args{checksum: utils.Sha256(content)}, wantRange: "", wantErr: false},
+		{name: "002", args: args{partial: content[:12345], checksum: utils.Sha256(content)}, wantRange: "bytes=12345-", wantErr: false},
+		{name: "003", args: args{checksum: utils.Sha256("other")}, wantRange: "", wantErr: true},
+		// 临时文件已下载完整: 服务端返回416
+		{name: "004", args: args{partial: content, checksum: utils.Sha256(content)}, wantRange: "bytes=100000-", wantErr: false},
+		// 临时文件大于服务端文件: 删除临时文件重新下载
+		{name: "005", args: args{partial: content + "x", checksum: utils.Sha256(content)}, wantRange: "bytes=100001-,", wantErr: false},
+		{name: "006", args: args{partial: content, checksum: utils.Sha256("other")}, wantRange: "bytes=100000-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges = ranges[:0]
+			dest := filepath.Join(dir, tt.name+".txt")
+			if tt.args.partial != "" {
+				if err := os.WriteFile(dest+".download", []byte(tt.args.partial), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var written, total int64
+			err := utils.NewCurl().Download(srv.URL, dest,
+				utils.WithDownloadChecksum(crypto.SHA256, tt.args.checksum),
+				utils.WithDownloadProgress(func(w, tl int64, rate float64) {
+					written, total = w, tl
+				}),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(ranges, ",") != tt.wantRange {
+				t.Errorf("Download() Range = %v, want %v", ranges, tt.wantRange)
+			}
+			if tt.wantErr {
+				if utils.IsExist(dest) || utils.IsExist(dest+".download") {
+					t.Errorf("Download() 校验失败后文件未删除")
+				}
+				return
+			}
+
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("Download() 文件内容不一致, len = %d, want %d", len(got), len(content))
+			}
+			if written != int64(len(content)) || total != int64(len(content)) {
+				t.Errorf("Download() progress = %d/%d, want %d", written, total, len(content))
+			}
+			if utils.IsExist(dest + ".download") {
+				t.Errorf("Download() 临时文件未删除")
+			}
+		})
+	}
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		w.Header().Set("X-Range", r.Header.Get("Range"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("part1"))
+		w.(http.Flusher).Flush()
+
+		// 读取响应体的时间超过 Curl 超时时间
+		time.Sleep(1500 * time.Millisecond)
+		_, _ = w.Write([]byte("part2"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	curl := utils.NewCurl(utils.WithCurlTimeout(time.Second))
+
+	// 超时时间只限制收到响应头之前的时间
+	dest := filepath.Join(dir, "slow.txt")
+	if err := os.WriteFile(dest+".download", []byte("part"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := curl.Download(srv.URL, dest); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "part1part2" {
+		t.Errorf("Download() = %q, want %q", got, "part1part2")
+	}
+
+	// 断点续传的 Range 只作用于本次请求
+	var header http.Header
+	curl.AfterResponse(func(resp *http.Response) (bool, error) {
+		header = resp.Header
+		return false, nil
+	})
+	if err := curl.Get(srv.URL + "/fast"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := header.Get("X-Range"); got != "" {
+		t.Errorf("Get() Range = %v, want empty", got)
+	}
+
+	// 超时时间内未收到响应头
+	err := utils.NewCurl(utils.WithCurlTimeout(500*time.Millisecond)).Download(srv.URL+"/hang", filepath.Join(dir, "hang.txt"))
+	if !errors.Is(err, utils.ErrStreamTimeout) {
+		t.Errorf("Download() error = %v, want ErrStreamTimeout", err)
+	}
+}
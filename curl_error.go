@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// HTTPError 响应状态码不是200且未通过 SetStatusCode 标记时返回的错误
+//
+//	使用 errors.As(err, &httpErr) 获取, 可根据 StatusCode 判断处理
+type HTTPError struct {
+	Method     string        // 请求方式
+	URL        string        // 请求地址(已脱敏)
+	StatusCode int           // 响应状态码
+	Status     string        // 响应状态
+	Header     http.Header   // 响应头(已脱敏)
+	Body       []byte        // 响应体预览内容(已脱敏)
+	Truncated  bool          // 响应体预览内容是否被截断
+	RequestId  string        // 请求ID
+	Attempts   int           // 请求次数(含重试)
+	Elapsed    time.Duration // 请求耗时(含重试等待时间)
+}
+
+// Error 实现Error接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("response error StatusCode: statusCode=%d, Status=%s, method=%s, url=%s", e.StatusCode, e.Status, e.Method, e.URL)
+}
+
+// MarshalJSON 实现 json.Marshaler 接口, errors.TraceJSON 使用该方法输出错误详情
+func (e *HTTPError) MarshalJSON() ([]byte, error) {
+	body := string(e.Body)
+	if e.Truncated {
+		body += "...[truncated]"
+	}
+	return json.Marshal(struct {
+		Method     string      `json:"method"`
+		URL        string      `json:"url"`
+		StatusCode int         `json:"status_code"`
+		Status     string      `json:"status"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body,omitempty"`
+		RequestId  string      `json:"request_id,omitempty"`
+		Attempts   int         `json:"attempts"`
+		Elapsed    string      `json:"elapsed"`
+	}{e.Method, e.URL, e.StatusCode, e.Status, e.Header, body, e.RequestId, e.Attempts, e.Elapsed.String()})
+}
+
+// LogValue 实现 slog.LogValuer 接口
+func (e *HTTPError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("method", e.Method),
+		slog.String("url", e.URL),
+		slog.Int("status_code", e.StatusCode),
+		slog.String("request_id", e.RequestId),
+		slog.Int("attempts", e.Attempts),
+		slog.Duration("elapsed", e.Elapsed),
+	)
+}
+
+// Temporary 是否是临时错误(可稍后重试): 429 及 5xx
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// newHTTPError 创建 HTTPError, 响应体按 limit 截取预览内容, 地址、响应头及响应体使用 r 脱敏
+func newHTTPError(req *http.Request, resp *http.Response, st *sendState, limit int64, r *Redactor) *HTTPError {
+	e := &HTTPError{
+		Method:     req.Method,
+		URL:        r.URL(req.URL),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     r.Header(resp.Header),
+		RequestId:  RequestIdFromContext(req.Context()),
+		Attempts:   st.attempts,
+		Elapsed:    time.Since(st.start),
+	}
+	if limit > 0 && resp.Body != nil && resp.Body != http.NoBody {
+		e.Body, e.Truncated, resp.Body, _ = readBodyPreviewAndRestore(ctxReadCloser(req.Context(), resp.Body), limit)
+		e.Body = r.Body(resp.Header.Get("Content-Type"), e.Body)
+	}
+	return e
+}
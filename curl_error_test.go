@@ -0,0 +1,65 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reason", "test")
+		if r.URL.Path == "/busy" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(strings.Repeat("x", 20)))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantAttempts int
+		wantBody     string
+	}{
+		{name: "001", path: "/none", wantStatus: http.StatusNotFound, wantAttempts: 1, wantBody: strings.Repeat("x", 10)},
+		{name: "002", path: "/busy", wantStatus: http.StatusServiceUnavailable, wantAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curl := utils.NewCurl(
+				utils.WithCurlDumpBodyLimit(10),
+				utils.WithCurlMaxRetry(3),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond)),
+			)
+			err := curl.Get(srv.URL + tt.path)
+
+			var httpErr *utils.HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("Get() error = %v, want *HTTPError", err)
+			}
+			if httpErr.StatusCode != tt.wantStatus || httpErr.Attempts != tt.wantAttempts || string(httpErr.Body) != tt.wantBody {
+				t.Errorf("HTTPError = %+v", httpErr)
+			}
+			if httpErr.Method != http.MethodGet || httpErr.URL != srv.URL+tt.path || httpErr.RequestId != curl.GetRequestId() ||
+				httpErr.Header.Get("X-Reason") != "test" || httpErr.Elapsed <= 0 {
+				t.Errorf("HTTPError = %+v", httpErr)
+			}
+			if tt.wantBody != "" && !httpErr.Truncated {
+				t.Errorf("HTTPError.Truncated = false, want true")
+			}
+			if !strings.Contains(errors.TraceJSON(err), `"status_code":`+strconv.Itoa(tt.wantStatus)) {
+				t.Errorf("TraceJSON() = %v", errors.TraceJSON(err))
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package utils_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestFormStream(t *testing.T) {
+	type args struct {
+		reader io.Reader // AddFileReader 上传的数据
+		failN  int32     // 前 failN 次请求返回 503
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantAttempt int32
+		wantErr     bool
+	}{
+		{name: "001", args: args{reader: bytes.NewReader([]byte("in-memory data")), failN: 0}, wantAttempt: 1, wantErr: false},
+		{name: "002", args: args{reader: bytes.NewReader([]byte("in-memory data")), failN: 1}, wantAttempt: 2, wantErr: false},
+		{name: "003", args: args{reader: io.MultiReader(strings.NewReader("in-memory data")), failN: 1}, wantAttempt: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempt atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempt.Add(1)
+				if err := r.ParseMultipartForm(10 << 20); err != nil {
+					t.Errorf("ParseMultipartForm() error = %v", err)
+					return
+				}
+				if r.FormValue("name") != "Lisa" {
+					t.Errorf("name = %v, want %v", r.FormValue("name"), "Lisa")
+				}
+
+				// 以 io.Reader 上传的文件
+				file, header, err := r.FormFile("data")
+				if err != nil {
+					t.Errorf("FormFile(data) error = %v", err)
+					return
+				}
+				b, _ := io.ReadAll(file)
+				if string(b) != "in-memory data" || header.Header.Get("Content-Type") != "text/plain" {
+					t.Errorf("data = %q, Content-Type = %v", b, header.Header.Get("Content-Type"))
+				}
+
+				// 自定义头信息的数据块
+				_, header, err = r.FormFile("meta")
+				if err != nil || header.Header.Get("X-Part-Id") != "1" {
+					t.Errorf("FormFile(meta) error = %v, header = %v", err, header)
+				}
+
+				// 以文件路径上传的文件
+				if _, _, err = r.FormFile("json_file"); err != nil {
+					t.Errorf("FormFile(json_file) error = %v", err)
+				}
+
+				if n <= tt.args.failN {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				utils.Json(w).Success(10000, "ok")
+			}))
+			defer srv.Close()
+
+			form := utils.Form{Params: map[string][]string{}, Files: map[string][]string{}}
+			meta := make(textproto.MIMEHeader)
+			meta.Set("Content-Disposition", `form-data; name="meta"; filename="meta.json"`)
+			meta.Set("X-Part-Id", "1")
+
+			var written int64
+			form.SetParam("name", "Lisa").
+				SetFile("json_file", "./json.go").
+				AddFileReader("data", "data.txt", tt.args.reader, "text/plain").
+				AddPart(meta, strings.NewReader(`{"id":1}`)).
+				OnProgress(func(w, total int64, rate float64) {
+					written = w
+				})
+
+			body, contentType := form.Stream()
+			curl := utils.NewCurl(
+				utils.WithCurlContentType(contentType),
+				utils.WithCurlMaxRetry(3),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))),
+			)
+
+			err := curl.Send(http.MethodPost, srv.URL, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempt.Load(); got != tt.wantAttempt {
+				t.Errorf("Send() attempt = %v, want %v", got, tt.wantAttempt)
+			}
+			if !tt.wantErr && written == 0 {
+				t.Errorf("OnProgress() 未回调")
+			}
+		})
+	}
+}
+
+func TestFormStreamGetBody(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	var (
+		attempt  atomic.Int32
+		received atomic.Value
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := attempt.Add(1)
+		if err := r.ParseMultipartForm(10 << 20); err != nil && err != http.ErrNotMultipart {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+		}
+		got := r.FormValue("name")
+		if file, _, err := r.FormFile("data"); err == nil {
+			b, _ := io.ReadAll(file)
+			got += ":" + string(b)
+		}
+		received.Store(got)
+		if n == 1 && r.URL.Path == "/flaky" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		multipart bool
+		path      string
+		mw        utils.Middleware
+		want      string
+	}{
+		{name: "001", multipart: true, mw: utils.LogMiddleware(utils.Log(), 0), want: "Lisa:" + string(data)},
+		{name: "002", multipart: true, path: "/flaky", mw: utils.DumpMiddleware(utils.Log(), 4096), want: "Lisa:" + string(data)},
+		{name: "003", path: "/flaky", mw: utils.LogMiddleware(utils.Log(), 0), want: "Lisa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempt.Store(0)
+			received.Store("")
+
+			form := utils.Form{Params: map[string][]string{}, Files: map[string][]string{}}
+			form.SetParam("name", "Lisa")
+			if tt.multipart {
+				form.AddFileReader("data", "data.txt", bytes.NewReader(data), "text/plain")
+			}
+			body, contentType := form.Stream()
+
+			// 日志预览及重试使用 GetBody, 不能影响正在发送的请求体
+			curl := utils.NewCurl(
+				utils.WithCurlContentType(contentType),
+				utils.WithCurlMiddleware(tt.mw),
+				utils.WithCurlMaxRetry(2),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))),
+			)
+			if err := curl.Send(http.MethodPost, srv.URL+tt.path, body); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got := received.Load().(string); got != tt.want {
+				t.Errorf("received = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormStreamClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// 熔断器在第一次失败后打开
+	open := func() *utils.CircuitBreaker {
+		return utils.NewCircuitBreaker(utils.WithBreakerFailureRatio(0.5, 1), utils.WithBreakerCoolDown(time.Hour))
+	}
+	opened := open()
+	_ = utils.NewCurl(utils.WithCurlCircuitBreaker(opened), utils.WithCurlMaxRetry(1)).Get(srv.URL)
+
+	tests := []struct {
+		name string
+		curl func() *utils.Curl
+	}{
+		// 熔断: 请求体未交给 Client.Do
+		{name: "001", curl: func() *utils.Curl {
+			return utils.NewCurl(utils.WithCurlCircuitBreaker(opened))
+		}},
+		// BeforeRequest 返回错误: 未进入 do
+		{name: "002", curl: func() *utils.Curl {
+			return utils.NewCurl().BeforeRequest(func(req *http.Request) error {
+				return io.ErrUnexpectedEOF
+			})
+		}},
+		// 重试时重新生成的请求体被熔断拒绝
+		{name: "003", curl: func() *utils.Curl {
+			return utils.NewCurl(
+				utils.WithCurlCircuitBreaker(open()),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))),
+			)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			for range 50 {
+				form := utils.Form{Params: map[string][]string{}, Files: map[string][]string{}}
+				form.SetParam("name", "Lisa").AddFileReader("data", "data.txt", bytes.NewReader(bytes.Repeat([]byte("x"), 1<<16)), "text/plain")
+				body, contentType := form.Stream()
+				if err := tt.curl().SetContentType(contentType).Send(http.MethodPost, srv.URL, body); err == nil {
+					t.Fatalf("Send() error = nil")
+				}
+			}
+
+			// 写入数据的协程退出
+			after := runtime.NumGoroutine()
+			for i := 0; i < 100 && after > before+5; i++ {
+				time.Sleep(10 * time.Millisecond)
+				after = runtime.NumGoroutine()
+			}
+			if after > before+5 {
+				t.Errorf("goroutines = %d, want <= %d", after, before+5)
+			}
+		})
+	}
+}
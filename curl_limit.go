@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+var (
+	// ErrRateLimited 请求频率超过限制(快速失败模式)
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrConcurrencyLimited 并发请求数超过限制(快速失败模式)
+	ErrConcurrencyLimited = errors.New("concurrency limited")
+)
+
+// HostLimiter 按请求的 Host 限制请求频率和并发数, 可在多个 Curl 实例间共享
+type HostLimiter struct {
+	mu sync.Mutex
+
+	// 每秒请求数, <=0 不限制
+	rps float64
+
+	// 令牌桶容量(突发请求数)
+	burst int
+
+	// 最大并发请求数, <=0 不限制
+	maxConcurrent int
+
+	// 超过限制时快速失败: true 返回 ErrRateLimited / ErrConcurrencyLimited; false 阻塞等待
+	failFast bool
+
+	// 每个 Host 的限制状态
+	hosts map[string]*hostLimit
+}
+
+// hostLimit 单个 Host 的令牌桶及并发信号量
+type hostLimit struct {
+	tokens float64
+	last   time.Time
+	sem    chan struct{}
+}
+
+// LimiterOption HostLimiter配置项
+type LimiterOption func(*HostLimiter)
+
+// WithLimiterFailFast 设置超过限制时是否快速失败, 默认: false 阻塞等待
+func WithLimiterFailFast(failFast bool) LimiterOption {
+	return func(l *HostLimiter) {
+		l.failFast = failFast
+	}
+}
+
+// NewHostLimiter 实例化HostLimiter
+//
+//	rps 每个 Host 每秒请求数, <=0 不限制
+//	burst 令牌桶容量(突发请求数), <=0 时为1
+//	maxConcurrent 每个 Host 最大并发请求数, <=0 不限制
+func NewHostLimiter(rps float64, burst, maxConcurrent int, opts ...LimiterOption) *HostLimiter {
+	l := &HostLimiter{
+		rps:           rps,
+		burst:         max(burst, 1),
+		maxConcurrent: maxConcurrent,
+		hosts:         make(map[string]*hostLimit),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(l)
+		}
+	}
+	return l
+}
+
+// SetRate 设置每秒请求数及令牌桶容量, 已创建的 Host 状态会被重置
+func (l *HostLimiter) SetRate(rps float64, burst int) *HostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rps, l.burst = rps, max(burst, 1)
+	clear(l.hosts)
+	return l
+}
+
+// SetMaxConcurrent 设置最大并发请求数, 已创建的 Host 状态会被重置
+func (l *HostLimiter) SetMaxConcurrent(n int) *HostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxConcurrent = n
+	clear(l.hosts)
+	return l
+}
+
+// SetFailFast 设置超过限制时是否快速失败
+func (l *HostLimiter) SetFailFast(failFast bool) *HostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failFast = failFast
+	return l
+}
+
+// host 获取 Host 的限制状态, 调用方需持有锁
+func (l *HostLimiter) host(host string) *hostLimit {
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimit{tokens: float64(l.burst), last: time.Now()}
+		if l.maxConcurrent > 0 {
+			h.sem = make(chan struct{}, l.maxConcurrent)
+		}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// Wait 获取一个请求令牌, 返回等待的时间
+//
+//	快速失败模式下令牌不足返回 ErrRateLimited; 否则阻塞等待直到获取令牌或 ctx 取消
+func (l *HostLimiter) Wait(ctx context.Context, host string) (time.Duration, error) {
+	l.mu.Lock()
+	if l.rps <= 0 {
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	h := l.host(host)
+
+	// 补充令牌
+	now := time.Now()
+	h.tokens = min(h.tokens+now.Sub(h.last).Seconds()*l.rps, float64(l.burst))
+	h.last = now
+
+	// 预占令牌
+	h.tokens--
+	if h.tokens >= 0 {
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	if l.failFast {
+		h.tokens++
+		l.mu.Unlock()
+		return 0, ErrRateLimited
+	}
+
+	wait := time.Duration(-h.tokens / l.rps * float64(time.Second))
+	l.mu.Unlock()
+
+	if err := sleepContext(ctx, wait); err != nil {
+		// 归还令牌
+		l.mu.Lock()
+		h.tokens++
+		l.mu.Unlock()
+		return 0, errors.Wrap(err)
+	}
+	return wait, nil
+}
+
+// Acquire 获取一个并发请求名额, 请求完成后需调用 release 释放
+//
+//	快速失败模式下名额不足返回 ErrConcurrencyLimited; 否则阻塞等待直到获取名额或 ctx 取消
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	l.mu.Lock()
+	if l.maxConcurrent <= 0 {
+		l.mu.Unlock()
+		return func() {}, nil
+	}
+	sem := l.host(host).sem
+	failFast := l.failFast
+	l.mu.Unlock()
+
+	release = func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if failFast {
+		return nil, ErrConcurrencyLimited
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err())
+	}
+}
@@ -0,0 +1,70 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestHostLimiter(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	t.Run("RateFailFast", func(t *testing.T) {
+		// 两个 Curl 共享限流器
+		limiter := utils.NewHostLimiter(1, 1, 0, utils.WithLimiterFailFast(true))
+		c1 := utils.NewCurl(utils.WithCurlLimiter(limiter))
+		c2 := utils.NewCurl(utils.WithCurlLimiter(limiter))
+		if err := c1.Get(srv.URL); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if err := c2.Get(srv.URL); !errors.Is(err, utils.ErrRateLimited) {
+			t.Errorf("Get() error = %v, want %v", err, utils.ErrRateLimited)
+		}
+	})
+
+	t.Run("RateWait", func(t *testing.T) {
+		curl := utils.NewCurl(utils.WithCurlRateLimit(20, 1))
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := curl.Get(srv.URL); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+		}
+		if spent := time.Since(start); spent < 90*time.Millisecond {
+			t.Errorf("Get() time spent = %v, want >= 100ms", spent)
+		}
+	})
+
+	t.Run("ConcurrentFailFast", func(t *testing.T) {
+		limiter := utils.NewHostLimiter(0, 0, 1, utils.WithLimiterFailFast(true))
+		done := make(chan error)
+		go func() {
+			done <- utils.NewCurl(utils.WithCurlLimiter(limiter)).Get(srv.URL + "/slow")
+		}()
+
+		// 等待第一个请求占用名额
+		time.Sleep(50 * time.Millisecond)
+		if err := utils.NewCurl(utils.WithCurlLimiter(limiter)).Get(srv.URL); !errors.Is(err, utils.ErrConcurrencyLimited) {
+			t.Errorf("Get() error = %v, want %v", err, utils.ErrConcurrencyLimited)
+		}
+
+		close(release)
+		if err := <-done; err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+		if err := utils.NewCurl(utils.WithCurlLimiter(limiter)).Get(srv.URL); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// defaultBodyLimit 日志中请求体、响应体预览内容长度默认上限
+const defaultBodyLimit int64 = 4096
+
+// RoundTripFunc 函数类型的 http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware 请求中间件: 包装下一个处理器 next 并返回新的处理器
+//
+//	每次 Send 只经过中间件链一次, 重试、限流、熔断在链的最内层完成
+//	中间件返回错误时应同时返回已获取的 Response, 以便 Curl 关闭 Response.Body
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain 使用中间件包装 rt, 第一个中间件在最外层
+func Chain(rt http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] != nil {
+			rt = mws[i](rt)
+		}
+	}
+	return rt
+}
+
+// LogMiddleware 记录请求和响应关键信息的中间件
+//
+//	bodyLimit 请求体、响应体预览内容长度: <=0 记录完整内容; 流式请求体(如 Form.Stream)总是只记录预览内容
+//	redactor 脱敏规则, 未设置时使用 DefaultRedactor()
+func LogMiddleware(logger Logger, bodyLimit int64, redactor ...*Redactor) Middleware {
+	r := defaultRedactor
+	if len(redactor) > 0 {
+		r = redactor[0]
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if logger == nil || !logger.Enabled(req.Context(), LevelInfo) {
+				return next.RoundTrip(req)
+			}
+
+			var (
+				b   strings.Builder
+				err error
+			)
+			b.WriteString(req.Method + ": " + r.URL(req.URL) + "\n")
+
+			if _, ok := req.Body.(*progressBody); ok {
+				// 流式请求体只记录预览内容
+				dump, err := dumpRequestSafe(req, Ternary(bodyLimit > 0, bodyLimit, defaultBodyLimit), r)
+				if err != nil {
+					return nil, errors.Wrap(err)
+				}
+				b.WriteString(dump)
+			} else if req.Body != nil && req.Body != http.NoBody {
+				b.WriteString("Request Body:\n")
+				var (
+					reqBody   []byte
+					truncated bool
+				)
+				if bodyLimit > 0 {
+					reqBody, truncated, req.Body, err = readBodyPreviewAndRestore(req.Body, bodyLimit)
+				} else {
+					reqBody, req.Body, err = DrainBody(req.Body)
+				}
+				if err != nil {
+					return nil, errors.Wrap(err)
+				}
+				b.Write(r.Body(req.Header.Get("Content-Type"), reqBody))
+				if truncated {
+					b.WriteString("\n...[truncated]")
+				}
+			}
+			logger.Info("DrainBody(req.Body)", "body", b.String()) // Info 日志
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+
+			// 只记录返回的关键信息
+			b.Reset()
+			b.WriteString(fmt.Sprintf("Response Status: %s\n", resp.Status))
+			b.WriteString("Response Body:\n")
+
+			var (
+				respBody  []byte
+				truncated bool
+			)
+			if bodyLimit > 0 {
+				respBody, truncated, resp.Body, err = readBodyPreviewAndRestore(ctxReadCloser(req.Context(), resp.Body), bodyLimit)
+			} else {
+				respBody, resp.Body, err = DrainBody(ctxReadCloser(req.Context(), resp.Body))
+			}
+			if err != nil {
+				return resp, errors.Wrap(err)
+			}
+			b.Write(r.Body(resp.Header.Get("Content-Type"), respBody))
+			if truncated {
+				b.WriteString("\n...[truncated]")
+			}
+			logger.Info("DrainBody(resp.Body)", "Body", b.String()) // Info 日志
+			return resp, nil
+		})
+	}
+}
+
+// DumpMiddleware 使用 httputil.DumpRequestOut, httputil.DumpResponse 记录请求和响应详细信息的中间件
+//
+//	bodyLimit 请求体、响应体预览内容长度: <=0 不记录请求体、响应体
+//	redactor 脱敏规则, 未设置时使用 DefaultRedactor()
+func DumpMiddleware(logger Logger, bodyLimit int64, redactor ...*Redactor) Middleware {
+	r := defaultRedactor
+	if len(redactor) > 0 {
+		r = redactor[0]
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if logger == nil || !logger.Enabled(req.Context(), LevelInfo) {
+				return next.RoundTrip(req)
+			}
+
+			dump, err := dumpRequestSafe(req, bodyLimit, r)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			logger.Info("httputil.DumpRequestOut()", "request", dump) // Info 日志
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+
+			if dump, err = dumpResponseSafe(resp, bodyLimit, r); err != nil {
+				return resp, errors.Wrap(err)
+			}
+			logger.Info("httputil.DumpResponse()", "response", dump) // Info 日志
+			return resp, nil
+		})
+	}
+}
+
+// RequestIdMiddleware 将请求ID写入请求头的中间件, 请求头已存在时不覆盖
+//
+//	header 请求头名称, 为空时: X-Request-Id
+//	请求ID优先取自 RequestIdFromContext, 不存在时生成 UniqId(16)
+func RequestIdMiddleware(header string) Middleware {
+	header = Ternary(strings.TrimSpace(header) == "", "X-Request-Id", strings.TrimSpace(header))
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				requestId := RequestIdFromContext(req.Context())
+				if requestId == "" {
+					requestId = UniqId(16)
+				}
+				req.Header.Set(header, requestId)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// AuthMiddleware 请求签名认证的中间件, sign 对请求签名并设置请求头或参数
+//
+//	签名在 BeforeRequest 之后、日志记录之前执行, 重试时不重新签名
+func AuthMiddleware(sign func(req *http.Request) error) Middleware {
+	return requestMiddleware(sign)
+}
+
+// MetricsMiddleware 统计请求结果及耗时的中间件
+//
+//	observe 请求完成后回调: resp 为最终响应(不含重试丢弃的响应), 请求失败时为nil; elapsed 含重试等待时间
+func MetricsMiddleware(observe func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			t := time.Now()
+			resp, err := next.RoundTrip(req)
+			if observe != nil {
+				observe(req, resp, err, time.Since(t))
+			}
+			return resp, err
+		})
+	}
+}
+
+// requestMiddleware 发送请求前使用 f 处理 Request 的中间件
+func requestMiddleware(f func(req *http.Request) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if f != nil {
+				if err := f(req); err != nil {
+					return nil, errors.Wrap(err)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Use 添加中间件, 先添加的在外层; 与 BeforeRequest 等方法不同, 多次调用不会覆盖已添加的中间件
+//
+//	中间件链顺序(外层 -> 内层): AfterDone -> 响应处理(AfterResponse, AfterBody) -> BeforeRequest -> Use 添加的中间件 -> 访问令牌 -> 默认日志 -> 压缩、解压 -> BeforeClient -> 发送请求(重试、限流、熔断)
+func (c *Curl) Use(mws ...Middleware) *Curl {
+	for _, mw := range mws {
+		if mw != nil {
+			c.middlewares = append(c.middlewares, mw)
+		}
+	}
+	return c
+}
+
+// WithCurlMiddleware 设置中间件
+func WithCurlMiddleware(mws ...Middleware) CurlOption {
+	return func(c *Curl) {
+		c.Use(mws...)
+	}
+}
+
+// handler 组装本次请求的中间件链
+func (c *Curl) handler(hooks sendHooks, st *sendState) http.RoundTripper {
+	mws := make([]Middleware, 0, len(c.middlewares)+7)
+
+	// 请求完成后的处理方法
+	if c.afterDone != nil {
+		f := c.afterDone
+		mws = append(mws, func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := next.RoundTrip(req)
+
+				// Debug 日志
+				if c.defLogOutput {
+					c.Logger.Debug("done()")
+				}
+				f(c.cli, req, resp)
+				return resp, err
+			})
+		})
+	}
+
+	// 响应处理
+	mws = append(mws, c.responseMiddleware(hooks, st))
+
+	// 在发送请求之前对 Request处理方法
+	if c.beforeRequest != nil {
+		f := c.beforeRequest
+		mws = append(mws, requestMiddleware(func(req *http.Request) error {
+			// Debug 日志
+			if c.defLogOutput {
+				c.Logger.Debug("request()")
+			}
+			return f(req)
+		}))
+	}
+
+	mws = append(mws, c.middlewares...)
+
+	// 访问令牌
+	if c.tokenSource != nil {
+		mws = append(mws, TokenMiddleware(c.tokenSource))
+	}
+
+	// 默认日志
+	if c.defLogOutput {
+		if hooks.live {
+			// 长连接流只记录请求头和响应头
+			mws = append(mws, DumpMiddleware(c.Logger, 0, c.redactor))
+		} else if c.dump {
+			mws = append(mws, DumpMiddleware(c.Logger, c.dumpBodyLimit, c.redactor))
+		} else {
+			// 流式读取响应体时只记录预览内容
+			mws = append(mws, LogMiddleware(c.Logger, Ternary(hooks.stream, c.dumpBodyLimit, 0), c.redactor))
+		}
+	}
+
+	// 压缩请求体、解压响应体
+	mws = append(mws, encodingMiddleware(c.compression))
+
+	// 在发送请求之前对Client处理方法
+	if c.beforeClient != nil {
+		f := c.beforeClient
+		mws = append(mws, requestMiddleware(func(*http.Request) error {
+			// Debug 日志
+			if c.defLogOutput {
+				c.Logger.Debug("client()")
+			}
+			return f(c.cli)
+		}))
+	}
+
+	return Chain(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return c.do(req, st)
+	}), mws...)
+}
+
+// responseMiddleware 校验状态码并执行 AfterResponse, AfterBody 及单次请求附加处理方法的中间件
+func (c *Curl) responseMiddleware(hooks sendHooks, st *sendState) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			return resp, c.resolve(req, resp, hooks, st)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCurlMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace-Id", r.Header.Get("X-Trace-Id"))
+		w.Header().Set("X-Sign", r.Header.Get("X-Sign"))
+		w.Header().Set("X-Before", r.Header.Get("X-Before"))
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	// 记录中间件执行顺序
+	var order []string
+	trace := func(name string) utils.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return utils.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" before")
+				resp, err := next.RoundTrip(req)
+				order = append(order, name+" after")
+				return resp, err
+			})
+		}
+	}
+
+	var (
+		statusCode int
+		elapsed    time.Duration
+		header     http.Header
+	)
+	curl := utils.NewCurl(utils.WithCurlMiddleware(trace("a")))
+	curl.Use(
+		trace("b"),
+		utils.RequestIdMiddleware("X-Trace-Id"),
+		utils.AuthMiddleware(func(req *http.Request) error {
+			// 签名在 BeforeRequest 之后执行
+			req.Header.Set("X-Sign", "sign:"+req.Header.Get("X-Before"))
+			return nil
+		}),
+		utils.MetricsMiddleware(func(req *http.Request, resp *http.Response, err error, d time.Duration) {
+			statusCode, elapsed = resp.StatusCode, d
+		}),
+	).BeforeRequest(func(req *http.Request) error {
+		order = append(order, "request()")
+		req.Header.Set("X-Before", "1")
+		return nil
+	}).AfterResponse(func(resp *http.Response) (bool, error) {
+		order = append(order, "response()")
+		header = resp.Header
+		return false, nil
+	}).AfterBody(func(body []byte) error {
+		order = append(order, "body()")
+		return nil
+	}).BeforeClient(func(cli *http.Client) error {
+		order = append(order, "client()")
+		return nil
+	}).AfterDone(func(cli *http.Client, req *http.Request, resp *http.Response) {
+		order = append(order, "done()")
+		if cli == nil || req == nil || resp == nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("AfterDone() cli = %v, req = %v, resp = %v", cli, req, resp)
+		}
+	})
+
+	if err := curl.Get(srv.URL); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	wantOrder := []string{"request()", "a before", "b before", "client()", "b after", "a after", "response()", "body()", "done()"}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("order = %v, want %v", order, wantOrder)
+	}
+	if got := header.Get("X-Trace-Id"); got != curl.GetRequestId() {
+		t.Errorf("X-Trace-Id = %v, want %v", got, curl.GetRequestId())
+	}
+	if got := header.Get("X-Sign"); got != "sign:1" {
+		t.Errorf("X-Sign = %v, want %v", got, "sign:1")
+	}
+	if statusCode != http.StatusOK || elapsed <= 0 {
+		t.Errorf("Metrics statusCode = %v, elapsed = %v", statusCode, elapsed)
+	}
+
+	// 中间件可以直接返回响应, 不发送请求
+	order = order[:0]
+	curl = utils.NewCurl().Use(func(next http.RoundTripper) http.RoundTripper {
+		return utils.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			rec.WriteHeader(http.StatusTeapot)
+			return rec.Result(), nil
+		})
+	})
+	if err := curl.Get("http://127.0.0.1:0/unreachable"); err == nil {
+		t.Errorf("Get() error = nil, want status error")
+	}
+	if err := curl.SetStatusCode(http.StatusTeapot).Get("http://127.0.0.1:0/unreachable"); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+}
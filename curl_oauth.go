@@ -0,0 +1,338 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// Token 访问令牌
+type Token struct {
+	AccessToken  string    // 访问令牌
+	TokenType    string    // 令牌类型: 为空时 Bearer
+	RefreshToken string    // 刷新令牌
+	Expiry       time.Time // 过期时间: 零值永不过期
+}
+
+// Type 令牌类型, 用作请求头 Authorization 的认证方式
+func (t *Token) Type() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return t.TokenType
+}
+
+// Valid 令牌是否有效: 不为空且未过期
+func (t *Token) Valid() bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Before(t.Expiry))
+}
+
+// TokenSource 访问令牌来源
+//
+//	实现 Invalidate(token *Token) 方法时, TokenMiddleware 在响应状态码为401时使令牌失效并重试一次
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc 函数类型的 TokenSource
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token 实现 TokenSource 接口
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// StaticTokenSource 固定的访问令牌, 如长期有效的 Bearer 令牌
+func StaticTokenSource(accessToken string) TokenSource {
+	token := &Token{AccessToken: accessToken}
+	return TokenSourceFunc(func(context.Context) (*Token, error) {
+		return token, nil
+	})
+}
+
+// CachedTokenSource 缓存访问令牌, 在过期前主动刷新
+//
+//	多个 goroutine 并发获取令牌时只请求一次 src; 可在多个 Curl 实例间并发使用
+type CachedTokenSource struct {
+	src TokenSource
+
+	// 提前刷新时间
+	refreshBefore time.Duration
+
+	mu        sync.Mutex
+	token     *Token
+	refreshAt time.Time  // 开始主动刷新的时间
+	call      *tokenCall // 进行中的刷新
+	failures  int        // 连续主动刷新失败次数
+}
+
+// 主动刷新失败后的重试间隔: 从 tokenRetryMin 开始翻倍, 最大 tokenRetryMax
+const (
+	tokenRetryMin = time.Second
+	tokenRetryMax = 30 * time.Second
+)
+
+// tokenCall 进行中的刷新
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// TokenOption CachedTokenSource配置项
+type TokenOption func(*CachedTokenSource)
+
+// WithTokenRefreshBefore 设置在令牌过期前多久主动刷新, 默认: 1分钟; 最多为令牌有效期的一半
+func WithTokenRefreshBefore(d time.Duration) TokenOption {
+	return func(s *CachedTokenSource) {
+		s.refreshBefore = max(d, 0)
+	}
+}
+
+// NewCachedTokenSource 实例化CachedTokenSource, 令牌失效或临近过期时从 src 获取新令牌
+func NewCachedTokenSource(src TokenSource, opts ...TokenOption) *CachedTokenSource {
+	s := &CachedTokenSource{
+		src:           src,
+		refreshBefore: time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
+}
+
+// Token 获取访问令牌
+//
+//	令牌临近过期时在后台刷新并返回当前令牌; 令牌失效时等待刷新完成
+//	刷新不受调用方 ctx 取消的影响, ctx 取消时只停止等待
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	s.mu.Lock()
+	token := s.token
+	if token.Valid() && (s.refreshAt.IsZero() || time.Now().Before(s.refreshAt)) {
+		s.mu.Unlock()
+		return token, nil
+	}
+	call := s.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.call = call
+		go s.refresh(context.WithoutCancel(ctx), call)
+	}
+	s.mu.Unlock()
+
+	// 临近过期: 使用当前令牌
+	if token.Valid() {
+		return token, nil
+	}
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, errors.Wrap(call.err)
+		}
+		return call.token, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err())
+	}
+}
+
+// refresh 从 src 获取新令牌, 失败时保留当前令牌并推迟下次主动刷新
+func (s *CachedTokenSource) refresh(ctx context.Context, call *tokenCall) {
+	defer close(call.done)
+
+	token, err := s.src.Token(ctx)
+	if err == nil && (token == nil || token.AccessToken == "") {
+		err = errors.New("访问令牌为空")
+	}
+	call.token, call.err = token, err
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.call = nil
+	if err != nil {
+		// 当前令牌仍有效时推迟主动刷新, 避免每次获取令牌都请求 src
+		if s.token.Valid() {
+			s.refreshAt = time.Now().Add(min(tokenRetryMin<<min(s.failures, 5), tokenRetryMax))
+			s.failures++
+		}
+		return
+	}
+	s.token, s.refreshAt, s.failures = token, time.Time{}, 0
+	if !token.Expiry.IsZero() {
+		s.refreshAt = token.Expiry.Add(-min(s.refreshBefore, time.Until(token.Expiry)/2))
+	}
+}
+
+// Invalidate 使令牌失效, 下次获取令牌时重新获取; token 不是当前令牌时(已刷新)不处理
+func (s *CachedTokenSource) Invalidate(token *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && token != nil && s.token.AccessToken == token.AccessToken {
+		s.token = nil
+	}
+}
+
+// ClientCredentials OAuth2 客户端凭证模式(client_credentials)获取访问令牌
+type ClientCredentials struct {
+	TokenURL     string            // 令牌地址
+	ClientID     string            // 客户端ID
+	ClientSecret string            // 客户端密钥
+	Scopes       []string          // 权限范围
+	Params       map[string]string // 附加参数, 如: audience
+	AuthInBody   bool              // 客户端ID及密钥放在请求体中; 默认使用 Basic 认证
+	Options      []CurlOption      // 获取令牌请求的 Curl 配置项, 如超时时间、Transport、日志
+}
+
+// tokenRedactor 隐藏获取令牌请求中的客户端密钥及响应中的令牌
+var tokenRedactor = NewRedactor(
+	WithRedactFormKeys("client_secret", "client_assertion"),
+	WithRedactJSONPaths("access_token", "refresh_token", "id_token"),
+)
+
+// TokenSource 返回缓存令牌的 CachedTokenSource
+func (cc *ClientCredentials) TokenSource(opts ...TokenOption) *CachedTokenSource {
+	return NewCachedTokenSource(cc, opts...)
+}
+
+// Token 请求令牌地址获取新的访问令牌(不缓存)
+func (cc *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cc.Scopes) > 0 {
+		form.Set("scope", strings.Join(cc.Scopes, " "))
+	}
+	for k, v := range cc.Params {
+		form.Set(k, v)
+	}
+
+	c := NewCurl(WithCurlRedactor(tokenRedactor))
+	if cc.AuthInBody {
+		form.Set("client_id", cc.ClientID)
+		form.Set("client_secret", cc.ClientSecret)
+	} else {
+		c.SetBasicAuth(url.QueryEscape(cc.ClientID), url.QueryEscape(cc.ClientSecret))
+	}
+	for _, opt := range cc.Options {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	c.SetContentType("application/x-www-form-urlencoded").SetHeader("Accept", "application/json")
+
+	var token *Token
+	hooks := sendHooks{
+		body: func(resp *http.Response, body []byte) error {
+			var res struct {
+				AccessToken  string      `json:"access_token"`
+				TokenType    string      `json:"token_type"`
+				RefreshToken string      `json:"refresh_token"`
+				ExpiresIn    json.Number `json:"expires_in"`
+			}
+			if err := decodeJSON(body, &res); err != nil {
+				return fillDecodeError(newDecodeError(err, tokenRedactor.Body("json", body), c.dumpBodyLimit), resp)
+			}
+			if res.AccessToken == "" {
+				return errors.Errorf("令牌地址未返回 access_token: %s", cc.TokenURL)
+			}
+
+			token = &Token{AccessToken: res.AccessToken, TokenType: res.TokenType, RefreshToken: res.RefreshToken}
+			if expiresIn, err := res.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+				token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+			}
+			return nil
+		},
+	}
+
+	if err := c.send(ctx, http.MethodPost, cc.TokenURL, strings.NewReader(form.Encode()), hooks); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return token, nil
+}
+
+// WithCurlTokenSource 设置访问令牌来源
+func WithCurlTokenSource(ts TokenSource) CurlOption {
+	return func(c *Curl) {
+		c.SetTokenSource(ts)
+	}
+}
+
+// SetTokenSource 设置访问令牌来源, 发送请求时设置请求头 Authorization; 设置为nil取消
+//
+//	多个 Curl 实例共享同一个 CachedTokenSource 以复用令牌, 响应状态码为401时使用新令牌重试一次
+func (c *Curl) SetTokenSource(ts TokenSource) *Curl {
+	c.tokenSource = ts
+	return c
+}
+
+// GetTokenSource 获取访问令牌来源
+func (c *Curl) GetTokenSource() TokenSource {
+	return c.tokenSource
+}
+
+// TokenMiddleware 从 ts 获取访问令牌并设置请求头 Authorization 的中间件, 请求已设置 Authorization 时不处理
+//
+//	响应状态码为401且 ts 实现 Invalidate 方法时, 使令牌失效并使用新令牌重试一次
+func TokenMiddleware(ts TokenSource) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if ts == nil || req.Header.Get("Authorization") != "" {
+				return next.RoundTrip(req)
+			}
+
+			token, err := ts.Token(req.Context())
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+
+			// 重试前需重置请求体
+			release, err := rewindableBody(req)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			defer release()
+
+			req.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			inv, ok := ts.(interface{ Invalidate(token *Token) })
+			if !ok {
+				return resp, nil
+			}
+			inv.Invalidate(token)
+
+			// 获取新令牌失败、令牌未变化或请求体不可重复读取时返回401响应
+			fresh, err := ts.Token(req.Context())
+			if err != nil || fresh.AccessToken == token.AccessToken {
+				return resp, nil
+			}
+			if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+				return resp, nil
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				req.Body = body
+			}
+			discardBody(resp)
+
+			req.Header.Set("Authorization", fresh.Type()+" "+fresh.AccessToken)
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCurlTokenSource(t *testing.T) {
+	var (
+		fetches   atomic.Int32
+		expiresIn atomic.Int32
+		current   atomic.Value // 服务端当前有效的令牌
+	)
+	expiresIn.Store(3600)
+	current.Store("")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if id != "client" || secret != "s3cret" || r.PostFormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		token := fmt.Sprintf("tok-%d", fetches.Add(1))
+		current.Store(token)
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":%d,"scope":%q}`, token, expiresIn.Load(), r.PostFormValue("scope"))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.Method)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cc := &utils.ClientCredentials{
+		TokenURL:     srv.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	}
+
+	t.Run("single-flight", func(t *testing.T) {
+		fetches.Store(0)
+		ts := cc.TokenSource()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- utils.NewCurl(utils.WithCurlTokenSource(ts)).Get(srv.URL + "/api")
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+		}
+		if n := fetches.Load(); n != 1 {
+			t.Errorf("token fetches = %d, want 1", n)
+		}
+	})
+
+	t.Run("401 retry", func(t *testing.T) {
+		fetches.Store(0)
+		ts := cc.TokenSource()
+		if _, err := ts.Token(context.Background()); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+
+		// 服务端吊销令牌
+		current.Store("revoked")
+
+		var body string
+		err := utils.NewCurl(utils.WithCurlTokenSource(ts), utils.WithCurlBodyBytes([]byte(`{"a":1}`))).
+			AfterBody(func(b []byte) error {
+				body = string(b)
+				return nil
+			}).Post(srv.URL + "/api")
+		if err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		if body != http.MethodPost || fetches.Load() != 2 {
+			t.Errorf("body = %q, fetches = %d, want POST, 2", body, fetches.Load())
+		}
+
+		// 静态令牌不重试
+		err = utils.NewCurl(utils.WithCurlTokenSource(utils.StaticTokenSource("static"))).Get(srv.URL + "/api")
+		var he *utils.HTTPError
+		if !errors.As(err, &he) || he.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Get() error = %v, want HTTPError 401", err)
+		}
+	})
+
+	t.Run("proactive refresh", func(t *testing.T) {
+		fetches.Store(0)
+		expiresIn.Store(1)
+		defer expiresIn.Store(3600)
+
+		ts := cc.TokenSource()
+		tok1, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+
+		// 超过有效期的一半: 返回当前令牌并在后台刷新
+		time.Sleep(600 * time.Millisecond)
+		tok2, err := ts.Token(context.Background())
+		if err != nil || tok2 != tok1 {
+			t.Fatalf("Token() = %v, %v, want current token", tok2, err)
+		}
+		tok3 := tok2
+		for i := 0; i < 50 && tok3 == tok1; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if tok3, err = ts.Token(context.Background()); err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+		}
+		if tok3.AccessToken == tok1.AccessToken || fetches.Load() != 2 {
+			t.Errorf("Token() = %v, fetches = %d, want refreshed token", tok3, fetches.Load())
+		}
+	})
+
+	t.Run("refresh backoff", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := utils.NewCachedTokenSource(utils.TokenSourceFunc(func(context.Context) (*utils.Token, error) {
+			// 首次获取成功, 之后主动刷新均失败
+			if calls.Add(1) > 1 {
+				return nil, errors.New("token endpoint unavailable")
+			}
+			return &utils.Token{AccessToken: "tok", Expiry: time.Now().Add(time.Second)}, nil
+		}))
+		tok, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+
+		// 超过有效期的一半: 刷新失败后推迟下次刷新, 不会每次获取令牌都请求
+		time.Sleep(600 * time.Millisecond)
+		for range 50 {
+			if got, err := ts.Token(context.Background()); err != nil || got != tok {
+				t.Fatalf("Token() = %v, %v, want current token", got, err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if n := calls.Load(); n != 2 {
+			t.Errorf("token fetches = %d, want 2", n)
+		}
+	})
+
+	t.Run("AuthInBody", func(t *testing.T) {
+		tok, err := (&utils.ClientCredentials{
+			TokenURL:     srv.URL + "/token",
+			ClientID:     "client",
+			ClientSecret: "s3cret",
+			AuthInBody:   true,
+		}).Token(context.Background())
+		if err != nil || tok.Type() != "Bearer" || tok.Expiry.IsZero() {
+			t.Errorf("Token() = %+v, %v", tok, err)
+		}
+	})
+
+	t.Run("invalid client", func(t *testing.T) {
+		ts := (&utils.ClientCredentials{TokenURL: srv.URL + "/token", ClientID: "client", ClientSecret: "bad"}).TokenSource()
+		err := utils.NewCurl(utils.WithCurlTokenSource(ts)).Get(srv.URL + "/api")
+		var he *utils.HTTPError
+		if !errors.As(err, &he) || he.StatusCode != http.StatusUnauthorized || he.URL != srv.URL+"/token" {
+			t.Errorf("Get() error = %v, want token endpoint HTTPError 401", err)
+		}
+	})
+}
@@ -0,0 +1,359 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// defaultRedactor 默认脱敏规则: 只隐藏常见的敏感请求头、响应头
+var defaultRedactor = NewRedactor()
+
+// DefaultRedactor 获取默认脱敏规则, 未设置 WithCurlRedactor 的 Curl 使用该规则
+//
+//	默认隐藏的头信息: Authorization, Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key, X-Auth-Token, X-Access-Token, X-Csrf-Token
+func DefaultRedactor() *Redactor {
+	return defaultRedactor
+}
+
+// Redactor 日志脱敏: 隐藏日志及 dump 中的敏感头信息、url 参数、表单字段、JSON字段及匹配正则的内容
+//
+//	创建后不可修改, 可在多个 Curl 实例间并发使用; nil 不脱敏
+type Redactor struct {
+	// 请求头、响应头名称
+	headers map[string]bool
+
+	// url 参数及 application/x-www-form-urlencoded 表单字段名称(小写)
+	keys map[string]bool
+
+	// JSON 路径
+	paths [][]string
+
+	// 正则
+	patterns []*regexp.Regexp
+
+	// 替换内容
+	mask string
+}
+
+// RedactOption Redactor配置项
+type RedactOption func(*Redactor)
+
+// WithRedactHeaders 添加需隐藏的请求头、响应头名称, 如: X-Secret
+func WithRedactHeaders(names ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, name := range names {
+			r.headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+}
+
+// WithRedactFormKeys 添加需隐藏的 url 参数及 application/x-www-form-urlencoded 表单字段名称(不区分大小写), 如: password, token
+func WithRedactFormKeys(keys ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, key := range keys {
+			r.keys[strings.ToLower(strings.TrimSpace(key))] = true
+		}
+	}
+}
+
+// WithRedactJSONPaths 添加需隐藏的JSON字段路径(字段名不区分大小写), 值被替换为字符串
+//
+//	路径以 "." 分隔, "*" 匹配任意一个字段名或数组下标, "**" 匹配任意层级, 如:
+//	 - "password" 根对象的 password 字段
+//	 - "user.card.number" 嵌套对象的字段
+//	 - "cards.*.cvv" 数组中每个元素的 cvv 字段
+//	 - "**.token" 任意层级的 token 字段
+func WithRedactJSONPaths(paths ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, path := range paths {
+			if path = strings.TrimSpace(path); path != "" {
+				r.paths = append(r.paths, strings.Split(path, "."))
+			}
+		}
+	}
+}
+
+// WithRedactPatterns 添加正则, 隐藏请求体、响应体、url 及头信息中匹配的内容, 如银行卡号: regexp.MustCompile(`\b\d{16,19}\b`)
+func WithRedactPatterns(patterns ...*regexp.Regexp) RedactOption {
+	return func(r *Redactor) {
+		for _, p := range patterns {
+			if p != nil {
+				r.patterns = append(r.patterns, p)
+			}
+		}
+	}
+}
+
+// WithRedactMask 设置替换内容, 默认: ***
+func WithRedactMask(mask string) RedactOption {
+	return func(r *Redactor) {
+		r.mask = mask
+	}
+}
+
+// NewRedactor 实例化Redactor, 默认隐藏常见的敏感头信息(见 DefaultRedactor)
+func NewRedactor(opts ...RedactOption) *Redactor {
+	r := &Redactor{
+		headers: make(map[string]bool),
+		keys:    make(map[string]bool),
+		mask:    "***",
+	}
+	WithRedactHeaders("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+		"X-Api-Key", "X-Auth-Token", "X-Access-Token", "X-Csrf-Token")(r)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	return r
+}
+
+// Header 返回脱敏后的头信息副本, 认证头保留认证方式, 如: Bearer ***
+func (r *Redactor) Header(h http.Header) http.Header {
+	if r == nil || h == nil {
+		return h
+	}
+	out := h.Clone()
+	for key, values := range out {
+		for i, v := range values {
+			if !r.headers[key] {
+				values[i] = r.pattern(v)
+				continue
+			}
+			if scheme, _, ok := strings.Cut(v, " "); ok && strings.HasSuffix(key, "Authorization") {
+				values[i] = scheme + " " + r.mask
+			} else {
+				values[i] = r.mask
+			}
+		}
+	}
+	return out
+}
+
+// URL 返回脱敏后的 url: 隐藏密码及 WithRedactFormKeys 设置的参数
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if r == nil {
+		return u.Redacted()
+	}
+	s := r.redactURL(u).String()
+
+	// 密码中的替换内容不转义
+	if _, ok := u.User.Password(); ok {
+		s = strings.Replace(s, url.UserPassword("", r.mask).String(), ":"+r.mask, 1)
+	}
+	return r.pattern(s)
+}
+
+// rawURL 返回脱敏后的 url 字符串, 解析失败时只隐藏匹配正则的内容
+func (r *Redactor) rawURL(s string) string {
+	if r == nil {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return r.pattern(s)
+	}
+	return r.URL(u)
+}
+
+// host 返回隐藏匹配正则内容的主机名
+func (r *Redactor) host(host string) string {
+	if r == nil {
+		return host
+	}
+	return r.pattern(host)
+}
+
+// err 返回脱敏后的错误信息: *url.Error 中的 url 按 URL 规则隐藏, 并隐藏匹配正则的内容
+func (r *Redactor) err(err error) string {
+	if err == nil {
+		return ""
+	}
+	s := err.Error()
+	var ue *url.Error
+	if errors.As(err, &ue) && ue.URL != "" {
+		s = strings.ReplaceAll(s, ue.URL, r.rawURL(ue.URL))
+	}
+	if r == nil {
+		return s
+	}
+	return r.pattern(s)
+}
+
+// redactURL 返回隐藏密码及参数的 url 副本
+func (r *Redactor) redactURL(u *url.URL) *url.URL {
+	out := *u
+	if _, ok := out.User.Password(); ok {
+		out.User = url.UserPassword(out.User.Username(), r.mask)
+	}
+	out.RawQuery = r.query(out.RawQuery)
+	return &out
+}
+
+// Body 返回脱敏后的请求体、响应体, 内容可以是被截断的预览内容
+//
+//	contentType 为JSON或内容以 { [ 开头时按JSON路径隐藏, 为 application/x-www-form-urlencoded 时按表单字段隐藏
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	if len(r.paths) > 0 {
+		if trim := bytes.TrimSpace(body); strings.Contains(contentType, "json") || (len(trim) > 0 && (trim[0] == '{' || trim[0] == '[')) {
+			body = r.json(body)
+		}
+	}
+	if len(r.keys) > 0 && strings.Contains(contentType, "x-www-form-urlencoded") {
+		body = []byte(r.query(string(body)))
+	}
+	if len(r.patterns) > 0 {
+		body = []byte(r.pattern(string(body)))
+	}
+	return body
+}
+
+// query 隐藏 url 参数或表单字段的值, 保留原有顺序
+func (r *Redactor) query(query string) string {
+	if len(r.keys) == 0 || query == "" {
+		return query
+	}
+	parts := strings.Split(query, "&")
+	for i, part := range parts {
+		k, _, ok := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		if ok && r.keys[strings.ToLower(key)] {
+			parts[i] = k + "=" + r.mask
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// pattern 隐藏匹配正则的内容
+func (r *Redactor) pattern(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// json 按JSON路径隐藏字段值, 保留原有格式; 内容被截断时隐藏已解析的部分
+func (r *Redactor) json(body []byte) []byte {
+	w := &jsonRedactWalker{r: r, body: body, dec: json.NewDecoder(bytes.NewReader(body))}
+	w.dec.UseNumber()
+	_ = w.value(nil, false)
+	if len(w.spans) == 0 {
+		return body
+	}
+
+	mask, _ := json.Marshal(r.mask)
+	var (
+		out  = make([]byte, 0, len(body))
+		last int64
+	)
+	for _, span := range w.spans {
+		out = append(out, body[last:span[0]]...)
+		out = append(out, mask...)
+		last = span[1]
+	}
+	return append(out, body[last:]...)
+}
+
+// match 路径是否匹配需隐藏的JSON路径
+func (r *Redactor) match(path []string) bool {
+	for _, p := range r.paths {
+		if matchJSONPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchJSONPath 匹配JSON路径, "*" 匹配任意一个字段, "**" 匹配任意层级
+func matchJSONPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchJSONPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (pattern[0] != "*" && !strings.EqualFold(pattern[0], path[0])) {
+		return false
+	}
+	return matchJSONPath(pattern[1:], path[1:])
+}
+
+// jsonRedactWalker 遍历JSON并记录需隐藏的值的位置
+type jsonRedactWalker struct {
+	r     *Redactor
+	body  []byte
+	dec   *json.Decoder
+	spans [][2]int64
+}
+
+// value 读取一个JSON值, masked 表示上层已被隐藏
+func (w *jsonRedactWalker) value(path []string, masked bool) (err error) {
+	// 值的起始位置: 跳过空白及分隔符
+	start := w.dec.InputOffset()
+	for start < int64(len(w.body)) && strings.IndexByte(" \t\r\n:,", w.body[start]) >= 0 {
+		start++
+	}
+
+	redact := !masked && path != nil && w.r.match(path)
+	defer func() {
+		if !redact {
+			return
+		}
+		end := w.dec.InputOffset()
+		if err != nil {
+			// 内容被截断
+			end = int64(len(w.body))
+		}
+		w.spans = append(w.spans, [2]int64{start, end})
+	}()
+
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() {
+			key, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			if err = w.value(append(path[:len(path):len(path)], name), masked || redact); err != nil {
+				return err
+			}
+		}
+		_, err = w.dec.Token()
+	case json.Delim('['):
+		for i := 0; w.dec.More(); i++ {
+			if err = w.value(append(path[:len(path):len(path)], strconv.Itoa(i)), masked || redact); err != nil {
+				return err
+			}
+		}
+		_, err = w.dec.Token()
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 重试策略
+//
+//	由 Curl 在每次请求完成后调用，决定是否需要重试以及重试前的等待时间
+type RetryPolicy interface {
+	// Retry 判断第 attempt 次(从1开始)请求后是否需要重试
+	//	req 本次请求
+	//	resp 本次响应: 请求失败时为 nil
+	//	err 本次请求错误: 请求成功时为 nil
+	//	返回值 - wait 重试前等待时间; retry 是否重试
+	Retry(attempt int, req *http.Request, resp *http.Response, err error) (wait time.Duration, retry bool)
+}
+
+// RetryOption 重试策略配置项
+type RetryOption func(*retryRule)
+
+// retryRule 内置重试策略的公共判断规则
+type retryRule struct {
+	// 需要重试的响应状态码
+	statusCodes []int
+
+	// 非幂等请求(如POST)是否允许重试
+	nonIdempotent bool
+
+	// 是否遵循响应头 Retry-After
+	retryAfter bool
+
+	// Retry-After 等待时间上限
+	maxRetryAfter time.Duration
+}
+
+// WithRetryStatusCodes 设置需要重试的响应状态码, 默认: 429, 502, 503, 504
+func WithRetryStatusCodes(statusCodes ...int) RetryOption {
+	return func(r *retryRule) {
+		r.statusCodes = statusCodes
+	}
+}
+
+// WithRetryNonIdempotent 设置非幂等请求(如POST)是否允许重试, 默认: false
+//
+//	请求头设置了 Idempotency-Key 的请求视为幂等请求
+func WithRetryNonIdempotent(enable bool) RetryOption {
+	return func(r *retryRule) {
+		r.nonIdempotent = enable
+	}
+}
+
+// WithRetryAfter 设置是否遵循响应头 Retry-After 及其等待时间上限, 默认: 遵循, 上限1分钟
+func WithRetryAfter(enable bool, maxWait ...time.Duration) RetryOption {
+	return func(r *retryRule) {
+		r.retryAfter = enable
+		if len(maxWait) > 0 && maxWait[0] > 0 {
+			r.maxRetryAfter = maxWait[0]
+		}
+	}
+}
+
+func newRetryRule(opts ...RetryOption) retryRule {
+	r := retryRule{
+		statusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		nonIdempotent: false,
+		retryAfter:    true,
+		maxRetryAfter: time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&r)
+		}
+	}
+	return r
+}
+
+// match 判断是否满足重试条件, 满足时返回响应头 Retry-After 指定的等待时间
+func (r retryRule) match(req *http.Request, resp *http.Response, err error) (wait time.Duration, retry bool) {
+	if req != nil && !r.nonIdempotent && !IsIdempotent(req) {
+		return 0, false
+	}
+
+	// 传输错误
+	if err != nil {
+		return 0, true
+	}
+
+	if resp == nil || !IsHas(resp.StatusCode, r.statusCodes) {
+		return 0, false
+	}
+
+	if r.retryAfter {
+		if d, ok := RetryAfter(resp); ok {
+			return min(d, r.maxRetryAfter), true
+		}
+	}
+	return 0, true
+}
+
+// exponentialRetry 指数退避(带随机抖动)重试策略
+type exponentialRetry struct {
+	retryRule
+	base, maxWait time.Duration
+}
+
+// NewExponentialRetry 指数退避(带随机抖动)重试策略
+//
+//	base 首次重试等待时间, 之后每次翻倍
+//	maxWait 等待时间上限
+//	实际等待时间在 [d/2, d] 之间随机, d 为本次退避时间; 若响应头有 Retry-After 则以 Retry-After 为准
+func NewExponentialRetry(base, maxWait time.Duration, opts ...RetryOption) RetryPolicy {
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if maxWait < base {
+		maxWait = base
+	}
+	return &exponentialRetry{retryRule: newRetryRule(opts...), base: base, maxWait: maxWait}
+}
+
+// Retry 实现 RetryPolicy 接口
+func (r *exponentialRetry) Retry(attempt int, req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	wait, ok := r.match(req, resp, err)
+	if !ok {
+		return 0, false
+	}
+	if wait > 0 {
+		return wait, true
+	}
+
+	d := r.maxWait
+	if attempt < 32 {
+		d = min(r.base<<(attempt-1), r.maxWait)
+	}
+
+	// 随机抖动
+	half := d / 2
+	return half + rand.N(half+1), true
+}
+
+// constantRetry 固定间隔重试策略
+type constantRetry struct {
+	retryRule
+	delay time.Duration
+}
+
+// NewConstantRetry 固定间隔重试策略
+//
+//	delay 每次重试等待时间; 若响应头有 Retry-After 则以 Retry-After 为准
+func NewConstantRetry(delay time.Duration, opts ...RetryOption) RetryPolicy {
+	return &constantRetry{retryRule: newRetryRule(opts...), delay: max(delay, 0)}
+}
+
+// Retry 实现 RetryPolicy 接口
+func (r *constantRetry) Retry(_ int, req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	wait, ok := r.match(req, resp, err)
+	if !ok {
+		return 0, false
+	}
+	return Ternary(wait > 0, wait, r.delay), true
+}
+
+// defaultRetry 默认重试策略: 仅传输错误时重试, 间隔 8, 32, 128, 512 毫秒
+type defaultRetry struct{}
+
+// Retry 实现 RetryPolicy 接口
+func (defaultRetry) Retry(attempt int, _ *http.Request, _ *http.Response, err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	return time.Millisecond * time.Duration(2<<(2*attempt)), true
+}
+
+// IsIdempotent 判断请求是否是幂等请求
+//
+//	GET, HEAD, OPTIONS, TRACE, PUT, DELETE 及设置了请求头 Idempotency-Key 或 X-Idempotency-Key 的请求
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// RetryAfter 解析响应头 Retry-After, 支持秒数及 HTTP 日期格式
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
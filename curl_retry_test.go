@@ -0,0 +1,160 @@
+package utils_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	type args struct {
+		method   string
+		policy   utils.RetryPolicy
+		maxRetry uint8
+		failN    int32 // 前 failN 次请求返回 503
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantAttempt int32
+		wantErr     bool
+	}{
+		{name: "001", args: args{method: http.MethodGet, policy: utils.NewConstantRetry(time.Millisecond), maxRetry: 3, failN: 2}, wantAttempt: 3, wantErr: false},
+		{name: "002", args: args{method: http.MethodGet, policy: utils.NewExponentialRetry(time.Millisecond, 4*time.Millisecond), maxRetry: 2, failN: 5}, wantAttempt: 2, wantErr: true},
+		{name: "003", args: args{method: http.MethodPost, policy: utils.NewConstantRetry(time.Millisecond), maxRetry: 3, failN: 2}, wantAttempt: 1, wantErr: true},
+		{name: "004", args: args{method: http.MethodPost, policy: utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true)), maxRetry: 3, failN: 2}, wantAttempt: 3, wantErr: false},
+		{name: "005", args: args{method: http.MethodGet, policy: nil, maxRetry: 3, failN: 2}, wantAttempt: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempt atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempt.Add(1)
+
+				// 每次请求都应收到完整的请求体
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != "payload" {
+					t.Errorf("attempt %d body = %q, want %q", n, body, "payload")
+				}
+
+				if n <= tt.args.failN {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				utils.Json(w).Success(10000, "ok")
+			}))
+			defer srv.Close()
+
+			curl := utils.NewCurl(utils.WithCurlRetryPolicy(tt.args.policy), utils.WithCurlMaxRetry(tt.args.maxRetry))
+
+			// 不可重复读取的请求体
+			body := io.MultiReader(strings.NewReader("pay"), strings.NewReader("load"))
+			err := curl.Send(tt.args.method, srv.URL, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempt.Load(); got != tt.wantAttempt {
+				t.Errorf("Send() attempt = %v, want %v", got, tt.wantAttempt)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "001", header: "3", want: 3 * time.Second, wantOk: true},
+		{name: "002", header: "", want: 0, wantOk: false},
+		{name: "003", header: "abc", want: 0, wantOk: false},
+		{name: "004", header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set("Retry-After", tt.header)
+			got, ok := utils.RetryAfter(resp)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("RetryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRetryBody(t *testing.T) {
+	small := strings.Repeat("0123456789", 10)
+	large := strings.Repeat("0123456789", 110<<10) // 超出重试缓存上限
+
+	file, err := os.CreateTemp(t.TempDir(), "body")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString("skip:" + small); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+
+	pipe := func(s string) io.Reader {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = io.Copy(pw, strings.NewReader(s))
+			_ = pw.Close()
+		}()
+		return pr
+	}
+
+	tests := []struct {
+		name        string
+		body        func() io.Reader
+		want        string
+		wantAttempt int32
+		wantErr     bool
+	}{
+		// 可随机读取的请求体从当前位置重复读取
+		{name: "001", body: func() io.Reader { _, _ = file.Seek(5, io.SeekStart); return file }, want: small, wantAttempt: 2},
+		{name: "002", body: func() io.Reader { return pipe(small) }, want: small, wantAttempt: 2},
+		// 超出缓存上限不重试
+		{name: "003", body: func() io.Reader { return pipe(large) }, want: large, wantAttempt: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempt atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempt.Add(1)
+				b, _ := io.ReadAll(r.Body)
+				if string(b) != tt.want {
+					t.Errorf("body len = %v, want %v", len(b), len(tt.want))
+				}
+				if n == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				utils.Json(w).Success(10000, "ok")
+			}))
+			defer srv.Close()
+
+			curl := utils.NewCurl(
+				utils.WithCurlMaxRetry(2),
+				utils.WithCurlRetryPolicy(utils.NewConstantRetry(time.Millisecond, utils.WithRetryNonIdempotent(true))),
+			)
+			err := curl.Send(http.MethodPost, srv.URL, tt.body())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempt.Load(); got != tt.wantAttempt {
+				t.Errorf("Send() attempt = %v, want %v", got, tt.wantAttempt)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+var (
+	// ErrSignMissing 请求缺少签名、时间戳或随机数
+	ErrSignMissing = errors.New("signature missing")
+
+	// ErrSignExpired 请求时间戳超出允许的时间窗口
+	ErrSignExpired = errors.New("signature expired")
+
+	// ErrSignReplay 随机数已使用过(重放请求)
+	ErrSignReplay = errors.New("signature replayed")
+
+	// ErrSignInvalid 签名验证失败
+	ErrSignInvalid = errors.New("signature invalid")
+)
+
+// SignFunc 对待签名字符串签名, 返回编码后的签名串
+type SignFunc func(data string) (string, error)
+
+// VerifyFunc 验证待签名字符串的签名串, 验证失败返回错误
+type VerifyFunc func(data, sign string) error
+
+// HMACSigner HMAC 签名方法
+//
+//	hash 哈希函数标识, 如: crypto.SHA256
+//	encode 编码方法, 如: hex.EncodeToString, base64.StdEncoding.EncodeToString
+func HMACSigner(key []byte, hash crypto.Hash, encode EncodeToString) SignFunc {
+	return func(data string) (string, error) {
+		if !hash.Available() {
+			return "", errors.Errorf("不支持的哈希函数: %v", hash)
+		}
+		h := hmac.New(hash.New, key)
+		h.Write([]byte(data))
+		return encode(h.Sum(nil)), nil
+	}
+}
+
+// HMACVerifier HMAC 验证签名方法, 使用 hmac.Equal 比较签名防止时序攻击
+//
+//	decode 解码方法, 与 HMACSigner 的编码方法对应
+func HMACVerifier(key []byte, hash crypto.Hash, decode DecodeString) VerifyFunc {
+	return func(data, sign string) error {
+		if !hash.Available() {
+			return errors.Errorf("不支持的哈希函数: %v", hash)
+		}
+		signByte, err := decode(sign)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		h := hmac.New(hash.New, key)
+		h.Write([]byte(data))
+		if !hmac.Equal(h.Sum(nil), signByte) {
+			return ErrSignInvalid
+		}
+		return nil
+	}
+}
+
+// RSASigner RSA 签名方法, 使用 RSA.Sign(私钥)
+func RSASigner(r *RSA, hash crypto.Hash, encode EncodeToString) SignFunc {
+	return func(data string) (string, error) {
+		return r.Sign(data, hash, encode)
+	}
+}
+
+// RSAVerifier RSA 验证签名方法, 使用 RSA.Verify(公钥)
+func RSAVerifier(r *RSA, hash crypto.Hash, decode DecodeString) VerifyFunc {
+	return func(data, sign string) error {
+		if err := r.Verify(data, sign, hash, decode); err != nil {
+			return errors.Wrap(ErrSignInvalid, err.Error())
+		}
+		return nil
+	}
+}
+
+// RSAPSSSigner RSA-PSS 签名方法, 使用 RSA.SignPSS(私钥)
+func RSAPSSSigner(r *RSA, hash crypto.Hash, encode EncodeToString, opts *rsa.PSSOptions) SignFunc {
+	return func(data string) (string, error) {
+		return r.SignPSS(data, hash, encode, opts)
+	}
+}
+
+// RSAPSSVerifier RSA-PSS 验证签名方法, 使用 RSA.VerifyPSS(公钥)
+func RSAPSSVerifier(r *RSA, hash crypto.Hash, decode DecodeString, opts *rsa.PSSOptions) VerifyFunc {
+	return func(data, sign string) error {
+		if err := r.VerifyPSS(data, sign, hash, decode, opts); err != nil {
+			return errors.Wrap(ErrSignInvalid, err.Error())
+		}
+		return nil
+	}
+}
+
+// SignOption 请求签名及验证签名配置项
+type SignOption func(*signOptions)
+
+type signOptions struct {
+	// 签名、时间戳、随机数是否放在url参数中: false 放在请求头中
+	inParam bool
+
+	// 签名、时间戳、随机数的请求头名称或参数名称
+	signKey, timestampKey, nonceKey string
+
+	// 是否对请求体签名
+	body bool
+
+	// 随机数长度
+	nonceLen uint8
+
+	// 验证签名: 允许的时间戳偏差
+	window time.Duration
+
+	// 验证签名: 已使用的随机数
+	nonceStore NonceStore
+
+	// 验证签名: 请求体(含解压后的内容)长度上限
+	maxBody int64
+}
+
+// WithSignHeader 签名、时间戳、随机数放在请求头中(默认), 参数为空时使用默认名称: X-Signature, X-Timestamp, X-Nonce
+func WithSignHeader(signKey, timestampKey, nonceKey string) SignOption {
+	return func(o *signOptions) {
+		o.inParam = false
+		o.signKey = Ternary(signKey == "", "X-Signature", signKey)
+		o.timestampKey = Ternary(timestampKey == "", "X-Timestamp", timestampKey)
+		o.nonceKey = Ternary(nonceKey == "", "X-Nonce", nonceKey)
+	}
+}
+
+// WithSignParam 签名、时间戳、随机数放在url参数中, 参数为空时使用默认名称: sign, timestamp, nonce
+func WithSignParam(signKey, timestampKey, nonceKey string) SignOption {
+	return func(o *signOptions) {
+		o.inParam = true
+		o.signKey = Ternary(signKey == "", "sign", signKey)
+		o.timestampKey = Ternary(timestampKey == "", "timestamp", timestampKey)
+		o.nonceKey = Ternary(nonceKey == "", "nonce", nonceKey)
+	}
+}
+
+// WithSignBody 设置是否对请求体签名, 默认: true
+func WithSignBody(enable bool) SignOption {
+	return func(o *signOptions) {
+		o.body = enable
+	}
+}
+
+// WithSignNonceLen 设置随机数长度, 默认: 16
+func WithSignNonceLen(l uint8) SignOption {
+	return func(o *signOptions) {
+		if l > 0 {
+			o.nonceLen = l
+		}
+	}
+}
+
+// WithSignWindow 设置验证签名时允许的时间戳偏差, 默认: 5分钟
+func WithSignWindow(window time.Duration) SignOption {
+	return func(o *signOptions) {
+		if window > 0 {
+			o.window = window
+		}
+	}
+}
+
+// WithSignNonceStore 设置验证签名时记录已使用随机数的存储, 默认: 内存存储
+//
+//	多实例部署时应使用共享存储(如 Redis)
+func WithSignNonceStore(store NonceStore) SignOption {
+	return func(o *signOptions) {
+		if store != nil {
+			o.nonceStore = store
+		}
+	}
+}
+
+// WithSignMaxBody 设置验证签名时读取请求体(含解压后的内容)的长度上限, 默认: 10MB
+func WithSignMaxBody(n int64) SignOption {
+	return func(o *signOptions) {
+		if n > 0 {
+			o.maxBody = n
+		}
+	}
+}
+
+func newSignOptions(opts ...SignOption) *signOptions {
+	o := &signOptions{
+		signKey:      "X-Signature",
+		timestampKey: "X-Timestamp",
+		nonceKey:     "X-Nonce",
+		body:         true,
+		nonceLen:     16,
+		window:       5 * time.Minute,
+		maxBody:      10 << 20,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// SignString 生成待签名字符串, 各部分以换行符连接:
+//
+//	METHOD
+//	PATH
+//	排序后的url参数(url.Values.Encode, 不含签名参数)
+//	时间戳
+//	随机数
+//	请求体 SHA256 摘要(十六进制, 无请求体或不对请求体签名时为空; 压缩的请求体使用压缩前的内容)
+func SignString(method, path string, params url.Values, body []byte, timestamp, nonce string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(method) + "\n")
+	b.WriteString(Ternary(path == "", "/", path) + "\n")
+	b.WriteString(params.Encode() + "\n")
+	b.WriteString(timestamp + "\n")
+	b.WriteString(nonce + "\n")
+	if len(body) > 0 {
+		b.WriteString(Sha256(string(body)))
+	}
+	return b.String()
+}
+
+// SignMiddleware 请求签名中间件
+//
+//	sign 签名方法: HMACSigner, RSASigner, RSAPSSSigner 或自定义方法
+//	时间戳为秒级Unix时间戳, 随机数使用 UniqId 生成; 重试时不重新签名
+//	对压缩前的请求体签名(请求体在签名之后由 WithCurlRequestCompression 压缩)
+func SignMiddleware(sign SignFunc, opts ...SignOption) Middleware {
+	o := newSignOptions(opts...)
+	return AuthMiddleware(func(req *http.Request) error {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := UniqId(o.nonceLen)
+
+		var body []byte
+		if o.body {
+			var err error
+			if body, err = requestBody(req, 0); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+
+		query := req.URL.Query()
+		if o.inParam {
+			query.Set(o.timestampKey, timestamp)
+			query.Set(o.nonceKey, nonce)
+			query.Del(o.signKey)
+		}
+
+		signature, err := sign(SignString(req.Method, req.URL.EscapedPath(), query, body, timestamp, nonce))
+		if err != nil {
+			return errors.Wrap(err)
+		}
+
+		if o.inParam {
+			query.Set(o.signKey, signature)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(o.timestampKey, timestamp)
+			req.Header.Set(o.nonceKey, nonce)
+			req.Header.Set(o.signKey, signature)
+		}
+		return nil
+	})
+}
+
+// requestBody 读取请求体, 优先使用 GetBody 不影响原请求体; 否则读取后重置请求体
+//
+//	limit 请求体长度上限: <=0 不限制; 超出时返回 *http.MaxBytesError
+func requestBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if limit > 0 {
+			body = http.MaxBytesReader(nil, body, limit)
+		}
+		defer body.Close()
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return b, nil
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	if limit > 0 {
+		req.Body = http.MaxBytesReader(nil, req.Body, limit)
+	}
+	body, req.Body, err = DrainBody(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// NonceStore 记录已使用的随机数
+type NonceStore interface {
+	// Seen 记录随机数, 随机数在 expire 之前已记录过返回 true
+	Seen(nonce string, expire time.Time) bool
+}
+
+// memoryNonceStore 内存存储的已使用随机数
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	clean  time.Time
+}
+
+// Seen 实现 NonceStore 接口
+func (s *memoryNonceStore) Seen(nonce string, expire time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// 定期清理过期的随机数
+	if now.After(s.clean) {
+		for k, v := range s.nonces {
+			if now.After(v) {
+				delete(s.nonces, k)
+			}
+		}
+		s.clean = now.Add(time.Minute)
+	}
+
+	if v, ok := s.nonces[nonce]; ok && now.Before(v) {
+		return true
+	}
+	s.nonces[nonce] = expire
+	return false
+}
+
+// SignVerifier 服务端验证请求签名, 与 SignMiddleware 使用相同的 SignOption 配置
+type SignVerifier struct {
+	verify VerifyFunc
+	opts   *signOptions
+}
+
+// NewSignVerifier 实例化SignVerifier
+//
+//	verify 验证签名方法: HMACVerifier, RSAVerifier, RSAPSSVerifier 或自定义方法
+func NewSignVerifier(verify VerifyFunc, opts ...SignOption) *SignVerifier {
+	o := newSignOptions(opts...)
+	if o.nonceStore == nil {
+		o.nonceStore = &memoryNonceStore{nonces: make(map[string]time.Time)}
+	}
+	return &SignVerifier{verify: verify, opts: o}
+}
+
+// Verify 验证请求签名, 读取请求体后会重置 r.Body
+//
+//	请求体按 Content-Encoding(gzip, deflate) 解压后验证签名, r.Body 重置为原始(压缩的)内容
+//	请求体及解压后的内容超出 WithSignMaxBody 设置的上限时返回 ErrSignInvalid
+//
+//	返回错误: ErrSignMissing, ErrSignExpired, ErrSignReplay, ErrSignInvalid
+func (v *SignVerifier) Verify(r *http.Request) error {
+	o := v.opts
+	query := r.URL.Query()
+
+	var signature, timestamp, nonce string
+	if o.inParam {
+		signature, timestamp, nonce = query.Get(o.signKey), query.Get(o.timestampKey), query.Get(o.nonceKey)
+		query.Del(o.signKey)
+	} else {
+		signature, timestamp, nonce = r.Header.Get(o.signKey), r.Header.Get(o.timestampKey), r.Header.Get(o.nonceKey)
+	}
+	if signature == "" || timestamp == "" || nonce == "" {
+		return ErrSignMissing
+	}
+
+	// 时间窗口
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Wrap(ErrSignInvalid, "timestamp: "+err.Error())
+	}
+	t := time.Unix(ts, 0)
+	if d := time.Since(t); d > o.window || d < -o.window {
+		return ErrSignExpired
+	}
+
+	var body []byte
+	if o.body {
+		if body, err = requestBody(r, o.maxBody); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return errors.Wrap(ErrSignInvalid, err.Error())
+			}
+			return errors.Wrap(err)
+		}
+		if body, err = decodeBody(r.Header.Get("Content-Encoding"), body, o.maxBody); err != nil {
+			return errors.Wrap(ErrSignInvalid, err.Error())
+		}
+	}
+
+	if err = v.verify(SignString(r.Method, r.URL.EscapedPath(), query, body, timestamp, nonce), signature); err != nil {
+		if errors.Is(err, ErrSignInvalid) {
+			return err
+		}
+		return errors.Wrap(ErrSignInvalid, err.Error())
+	}
+
+	// 签名验证通过后记录随机数, 防止伪造请求占用随机数
+	if o.nonceStore.Seen(nonce, t.Add(o.window)) {
+		return ErrSignReplay
+	}
+	return nil
+}
+
+// Middleware net/http 中间件, 验证失败响应 401 并返回JSON错误信息
+func (v *SignVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			Json(w, WithStatusCode(http.StatusUnauthorized)).Fail(http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,141 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestSignMiddleware(t *testing.T) {
+	files, err := utils.GenerateKeyRSA(t.TempDir()+"/", 1024)
+	if err != nil {
+		t.Fatalf("GenerateKeyRSA() error = %v", err)
+	}
+	r, err := utils.NewRSA(files[0], files[1], utils.WithRSAFilePath(true))
+	if err != nil {
+		t.Fatalf("NewRSA() error = %v", err)
+	}
+	key := []byte("secret")
+
+	tests := []struct {
+		name   string
+		sign   utils.SignFunc
+		verify utils.VerifyFunc
+		opts   []utils.SignOption
+	}{
+		{name: "001", sign: utils.HMACSigner(key, crypto.SHA256, hex.EncodeToString), verify: utils.HMACVerifier(key, crypto.SHA256, hex.DecodeString)},
+		{name: "002", sign: utils.RSASigner(r, crypto.SHA256, base64.StdEncoding.EncodeToString), verify: utils.RSAVerifier(r, crypto.SHA256, base64.StdEncoding.DecodeString), opts: []utils.SignOption{utils.WithSignParam("", "", "")}},
+		{name: "003", sign: utils.RSAPSSSigner(r, crypto.SHA256, base64.URLEncoding.EncodeToString, nil), verify: utils.RSAPSSVerifier(r, crypto.SHA256, base64.URLEncoding.DecodeString, nil), opts: []utils.SignOption{utils.WithSignHeader("X-Sign", "", "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := utils.NewSignVerifier(tt.verify, tt.opts...)
+			var gotBody string
+			srv := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+				utils.Json(w).Success(10000, "ok")
+			})))
+			defer srv.Close()
+
+			curl := utils.NewCurl().Use(utils.SignMiddleware(tt.sign, tt.opts...))
+			curl.SetParams(map[string]string{"b": "2", "a": "1"}).SetBody(strings.NewReader(`{"id":1}`))
+			if err := curl.Post(srv.URL + "/sign"); err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			if gotBody != `{"id":1}` {
+				t.Errorf("body = %v, want %v", gotBody, `{"id":1}`)
+			}
+
+			// 篡改请求参数
+			curl = utils.NewCurl().Use(utils.SignMiddleware(tt.sign, tt.opts...), utils.AuthMiddleware(func(req *http.Request) error {
+				req.URL.RawQuery += "&c=3"
+				return nil
+			}))
+			if err := curl.Get(srv.URL + "/sign"); err == nil {
+				t.Errorf("Get() error = nil, want 401")
+			}
+		})
+	}
+}
+
+func TestSignVerifier(t *testing.T) {
+	key := []byte("secret")
+	sign := utils.HMACSigner(key, crypto.SHA256, hex.EncodeToString)
+	verifier := utils.NewSignVerifier(utils.HMACVerifier(key, crypto.SHA256, hex.DecodeString))
+
+	newRequest := func(timestamp, nonce, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api?b=2&a=1", strings.NewReader(body))
+		s, _ := sign(utils.SignString(req.Method, "/api", req.URL.Query(), []byte(body), timestamp, nonce))
+		req.Header.Set("X-Signature", s)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Nonce", nonce)
+		return req
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		wantErr error
+	}{
+		{name: "001", req: newRequest(now, "n1", "body")},
+		{name: "002", req: newRequest(now, "n1", "body"), wantErr: utils.ErrSignReplay},
+		{name: "003", req: newRequest("1000", "n2", "body"), wantErr: utils.ErrSignExpired},
+		{name: "004", req: httptest.NewRequest(http.MethodGet, "/api", nil), wantErr: utils.ErrSignMissing},
+		{name: "005", req: func() *http.Request {
+			req := newRequest(now, "n3", "body")
+			req.Body = io.NopCloser(strings.NewReader("tampered"))
+			return req
+		}(), wantErr: utils.ErrSignInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.req)
+			if (tt.wantErr == nil) != (err == nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("MaxBody", func(t *testing.T) {
+		limited := utils.NewSignVerifier(utils.HMACVerifier(key, crypto.SHA256, hex.DecodeString), utils.WithSignMaxBody(64))
+
+		// 请求体超出上限
+		if err := limited.Verify(newRequest(now, "m1", strings.Repeat("a", 65))); !errors.Is(err, utils.ErrSignInvalid) {
+			t.Errorf("Verify() 请求体超出上限 error = %v, want %v", err, utils.ErrSignInvalid)
+		}
+
+		// 解压后的内容超出上限
+		plain := strings.Repeat("a", 1024)
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(plain))
+		_ = zw.Close()
+		req := newRequest(now, "m2", plain)
+		req.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Content-Encoding", "gzip")
+		if buf.Len() > 64 {
+			t.Fatalf("gzip len = %v", buf.Len())
+		}
+		if err := limited.Verify(req); !errors.Is(err, utils.ErrSignInvalid) {
+			t.Errorf("Verify() 解压后超出上限 error = %v, want %v", err, utils.ErrSignInvalid)
+		}
+
+		if err := limited.Verify(newRequest(now, "m3", strings.Repeat("a", 64))); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	})
+}
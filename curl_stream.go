@@ -0,0 +1,366 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// ErrStreamTimeout 流式请求在超时时间内未收到响应头
+var ErrStreamTimeout = errors.New("stream response header timeout")
+
+// SSEEvent Server-Sent Events 事件
+type SSEEvent struct {
+	ID    string // 事件ID: 最近一次收到的 id 字段, 重连时作为请求头 Last-Event-ID 发送
+	Event string // 事件类型: 默认 message
+	Data  string // 事件数据: 多行 data 字段以 "\n" 连接
+}
+
+// StreamOption 流式请求配置项
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	reconnect   int
+	retry       time.Duration
+	maxLineSize int
+}
+
+// WithStreamReconnect 设置 SSE 连接断开后的最大连续重连次数, 默认: 3; 0 不重连, <0 不限制
+//
+//	收到事件后重新计数; 响应状态码错误、ctx 取消、handler 返回错误时不重连
+func WithStreamReconnect(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.reconnect = n
+	}
+}
+
+// WithStreamRetry 设置 SSE 重连间隔, 默认: 3秒; 服务端通过 retry 字段设置的间隔优先
+func WithStreamRetry(retry time.Duration) StreamOption {
+	return func(o *streamOptions) {
+		o.retry = max(retry, 0)
+	}
+}
+
+// WithStreamMaxLineSize 设置单行数据最大长度, 默认: 1MB
+func WithStreamMaxLineSize(size int) StreamOption {
+	return func(o *streamOptions) {
+		o.maxLineSize = size
+	}
+}
+
+// newStreamOptions 流式请求配置
+func newStreamOptions(opts []StreamOption) *streamOptions {
+	o := &streamOptions{
+		reconnect:   3,
+		retry:       3 * time.Second,
+		maxLineSize: int(MB),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// StreamSSE 发起请求并逐个解析 Server-Sent Events 事件, 交给 handler 处理
+//
+//	handler 返回 DONE 正常终止; 返回其它错误终止并返回该错误
+func (c *Curl) StreamSSE(method, url string, body io.Reader, handler func(event *SSEEvent) error, opts ...StreamOption) error {
+	return c.StreamSSEContext(context.Background(), method, url, body, handler, opts...)
+}
+
+// StreamSSEContext 携带 context 发起请求并逐个解析 Server-Sent Events 事件, 交给 handler 处理
+//
+//	连接断开后按 WithStreamReconnect 重连, 并使用请求头 Last-Event-ID 发送最近收到的事件ID
+//	Curl 超时时间只限制收到响应头之前的时间, 读取事件由 ctx 控制
+//	handler 返回 DONE 正常终止; 返回其它错误终止并返回该错误
+func (c *Curl) StreamSSEContext(ctx context.Context, method, url string, body io.Reader, handler func(event *SSEEvent) error, opts ...StreamOption) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cfg := newStreamOptions(opts)
+
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 重连时重新发送请求体
+	data, err := readStreamBody(body)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	// 本次请求的请求头, 不修改 Curl 的请求头
+	header := http.Header{"Accept": {"text/event-stream"}, "Cache-Control": {"no-cache"}}
+
+	p := &sseParser{retry: cfg.retry, handler: handler}
+	for attempt := 0; ; attempt++ {
+		if p.id != "" {
+			header.Set("Last-Event-ID", p.id)
+		}
+
+		p.reset()
+		err = c.stream(ctx, method, url, data, header, cfg, p.line)
+
+		// handler 终止
+		if p.stopped {
+			return nil
+		}
+		if p.err != nil {
+			return errors.Wrap(err)
+		}
+
+		// 状态码错误、ctx 取消不重连
+		var he *HTTPError
+		if errors.As(err, &he) {
+			return errors.Wrap(err)
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(Ternary(err != nil, err, ctx.Err()))
+		}
+
+		// 收到事件后重新计数
+		if p.received {
+			attempt = 0
+		}
+		if cfg.reconnect >= 0 && attempt >= cfg.reconnect {
+			return errors.Wrap(err)
+		}
+
+		// Warn 日志
+		c.Logger.Warn("StreamSSE reconnect", "url", c.redactor.rawURL(url), "lastEventId", p.id, "retry", p.retry.String(), "err", c.redactor.err(err))
+
+		if err = sleepContext(ctx, p.retry); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+}
+
+// StreamNDJSON 发起请求并逐行读取 NDJSON(每行一个JSON), 交给 handler 处理, 空行被忽略
+//
+//	handler 返回 DONE 正常终止; 返回其它错误终止并返回该错误
+func (c *Curl) StreamNDJSON(method, url string, body io.Reader, handler func(line []byte) error, opts ...StreamOption) error {
+	return c.StreamNDJSONContext(context.Background(), method, url, body, handler, opts...)
+}
+
+// StreamNDJSONContext 携带 context 发起请求并逐行读取 NDJSON(每行一个JSON), 交给 handler 处理, 空行被忽略
+//
+//	Curl 超时时间只限制收到响应头之前的时间, 读取数据由 ctx 控制; 连接断开后不重连
+//	handler 返回 DONE 正常终止; 返回其它错误终止并返回该错误
+func (c *Curl) StreamNDJSONContext(ctx context.Context, method, url string, body io.Reader, handler func(line []byte) error, opts ...StreamOption) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cfg := newStreamOptions(opts)
+
+	url, err = UrlPath(url, c.params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	data, err := readStreamBody(body)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	header := http.Header{"Accept": {"application/x-ndjson"}}
+	return errors.Wrap(c.stream(ctx, method, url, data, header, cfg, func(_ int, line []byte, _ error) error {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			return nil
+		}
+		return handler(line)
+	}))
+}
+
+// DecodeNDJSON 将 NDJSON 每行数据按JSON解码为T类型, 并交给f处理, 用于 StreamNDJSON 的 handler
+func DecodeNDJSON[T any](f func(v *T) error) func(line []byte) error {
+	return func(line []byte) error {
+		v := new(T)
+		if err := decodeJSON(line, v); err != nil {
+			return newDecodeError(err, line, defaultBodyLimit)
+		}
+		return f(v)
+	}
+}
+
+// stream 发起长连接流请求, 使用 Scan 逐行读取响应体交给 handle 处理
+func (c *Curl) stream(ctx context.Context, method, url string, body []byte, header http.Header, cfg *streamOptions, handle ReadScan) error {
+	t := c.headerTimer(ctx)
+	defer t.stop()
+
+	hooks := sendHooks{
+		live:   true,
+		header: header,
+		response: func(resp *http.Response) (bool, error) {
+			if err := t.received(); err != nil {
+				return true, errors.Wrap(err)
+			}
+			return true, Scan(ctxReadCloser(t.ctx, resp.Body), handle, cfg.maxLineSize)
+		},
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	return t.err(c.send(t.ctx, method, url, r, hooks))
+}
+
+// headerTimer 超时时间内未收到响应头时取消请求, 用于不限制总超时时间的请求(长连接流、下载)
+type headerTimer struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	wait    time.Duration
+	timeout atomic.Bool
+}
+
+// headerTimer 使用 Curl 超时时间创建 headerTimer
+func (c *Curl) headerTimer(ctx context.Context) *headerTimer {
+	t := &headerTimer{wait: Ternary(c.timeout > 0, c.timeout, 30*time.Second)}
+	t.ctx, t.cancel = context.WithCancelCause(ctx)
+	t.timer = time.AfterFunc(t.wait, func() {
+		t.timeout.Store(true)
+		t.cancel(ErrStreamTimeout)
+	})
+	return t
+}
+
+// received 收到响应头时停止计时, 已超时返回错误
+func (t *headerTimer) received() error {
+	if !t.timer.Stop() {
+		return errors.Wrap(context.Cause(t.ctx))
+	}
+	return nil
+}
+
+// err 已超时时返回 ErrStreamTimeout
+func (t *headerTimer) err(err error) error {
+	if err != nil && t.timeout.Load() {
+		return errors.Wrapf(ErrStreamTimeout, "timeout=%v, err=%v", t.wait, err)
+	}
+	return err
+}
+
+// stop 停止计时并释放 ctx
+func (t *headerTimer) stop() {
+	t.timer.Stop()
+	t.cancel(nil)
+}
+
+// readStreamBody 读取请求体, 用于重连时重新发送
+func readStreamBody(body io.Reader) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return data, nil
+}
+
+// sseParser 按 Server-Sent Events 规范解析事件
+type sseParser struct {
+	handler func(event *SSEEvent) error
+
+	// 最近收到的事件ID
+	id string
+
+	// 重连间隔
+	retry time.Duration
+
+	// 当前事件
+	event   string
+	data    bytes.Buffer
+	hasData bool
+
+	received bool  // 当前连接是否收到过事件
+	stopped  bool  // handler 返回 DONE
+	err      error // handler 返回的错误
+}
+
+// reset 重新连接时丢弃未完成的事件
+func (p *sseParser) reset() {
+	p.event, p.hasData, p.received = "", false, false
+	p.data.Reset()
+}
+
+// line 处理一行数据
+func (p *sseParser) line(num int, line []byte, _ error) error {
+	// 去除 BOM
+	if num == 1 {
+		line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF"))
+	}
+
+	// 空行: 分发事件
+	if len(line) == 0 {
+		return p.dispatch()
+	}
+
+	// 注释
+	if line[0] == ':' {
+		return nil
+	}
+
+	field, value, found := bytes.Cut(line, []byte(":"))
+	if found && len(value) > 0 && value[0] == ' ' {
+		value = value[1:]
+	}
+
+	switch string(field) {
+	case "event":
+		p.event = string(value)
+	case "data":
+		if p.hasData {
+			p.data.WriteByte('\n')
+		}
+		p.data.Write(value)
+		p.hasData = true
+	case "id":
+		if bytes.IndexByte(value, 0) < 0 {
+			p.id = string(value)
+		}
+	case "retry":
+		if ms, err := strconv.ParseUint(string(value), 10, 32); err == nil {
+			p.retry = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return nil
+}
+
+// dispatch 分发事件, 没有 data 字段的事件被忽略
+func (p *sseParser) dispatch() error {
+	defer func() {
+		p.event, p.hasData = "", false
+		p.data.Reset()
+	}()
+
+	if !p.hasData {
+		return nil
+	}
+
+	p.received = true
+	err := p.handler(&SSEEvent{
+		ID:    p.id,
+		Event: Ternary(p.event == "", "message", p.event),
+		Data:  p.data.String(),
+	})
+	if err != nil {
+		if errors.Is(err, DONE) {
+			p.stopped = true
+		} else {
+			p.err = err
+		}
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package utils_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestStreamSSE(t *testing.T) {
+	var conns atomic.Int32
+	lastEventIds := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := conns.Add(1)
+		lastEventIds <- r.Header.Get("Last-Event-ID")
+		switch r.URL.Path {
+		case "/none":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		switch {
+		case r.URL.Path == "/block":
+			fmt.Fprint(w, "data: first\n\n")
+			flusher.Flush()
+			<-r.Context().Done()
+		case r.URL.Path == "/empty":
+		case n == 1:
+			fmt.Fprint(w, ": comment\nretry: 10\n\nid: 1\nevent: greet\ndata: hello\ndata:  world\n\n")
+			flusher.Flush()
+			fmt.Fprint(w, "id: 2\r\ndata: x\r\n\r\ndata: lost\n")
+		default:
+			fmt.Fprint(w, "id: 3\ndata: y\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("Reconnect", func(t *testing.T) {
+		var events []utils.SSEEvent
+		curl := utils.NewCurl()
+		err := curl.StreamSSE(http.MethodGet, srv.URL, nil, func(event *utils.SSEEvent) error {
+			events = append(events, *event)
+			if event.ID == "3" {
+				return utils.DONE
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamSSE() error = %v", err)
+		}
+		want := []utils.SSEEvent{
+			{ID: "1", Event: "greet", Data: "hello\n world"},
+			{ID: "2", Event: "message", Data: "x"},
+			{ID: "3", Event: "message", Data: "y"},
+		}
+		if fmt.Sprint(events) != fmt.Sprint(want) {
+			t.Errorf("events = %q, want %q", events, want)
+		}
+		if id1, id2 := <-lastEventIds, <-lastEventIds; id1 != "" || id2 != "2" {
+			t.Errorf("Last-Event-ID = %q, %q, want \"\", \"2\"", id1, id2)
+		}
+
+		// 请求头只作用于本次请求
+		if h := curl.GetHeader(); h.Get("Accept") != "" || h.Get("Cache-Control") != "" || h.Get("Last-Event-ID") != "" {
+			t.Errorf("GetHeader() = %v", h)
+		}
+	})
+
+	t.Run("ReconnectLimit", func(t *testing.T) {
+		conns.Store(0)
+		err := utils.NewCurl().StreamSSE(http.MethodGet, srv.URL+"/empty", nil, func(event *utils.SSEEvent) error {
+			return nil
+		}, utils.WithStreamReconnect(2), utils.WithStreamRetry(time.Millisecond))
+		if err != nil || conns.Load() != 3 {
+			t.Errorf("StreamSSE() error = %v, conns = %d, want 3", err, conns.Load())
+		}
+	})
+
+	t.Run("StatusCode", func(t *testing.T) {
+		conns.Store(0)
+		err := utils.NewCurl().StreamSSE(http.MethodGet, srv.URL+"/none", nil, func(event *utils.SSEEvent) error {
+			return nil
+		}, utils.WithStreamRetry(time.Millisecond))
+		var he *utils.HTTPError
+		if !errors.As(err, &he) || conns.Load() != 1 {
+			t.Errorf("StreamSSE() error = %v, conns = %d, want HTTPError without reconnect", err, conns.Load())
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		start := time.Now()
+		err := utils.NewCurl().StreamSSEContext(ctx, http.MethodGet, srv.URL+"/block", nil, func(event *utils.SSEEvent) error {
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+			t.Errorf("StreamSSEContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		err := utils.NewCurl(utils.WithCurlTimeout(50*time.Millisecond), utils.WithCurlMaxRetry(0)).
+			StreamSSE(http.MethodGet, srv.URL+"/slow", nil, func(event *utils.SSEEvent) error {
+				return nil
+			}, utils.WithStreamReconnect(0))
+		if !errors.Is(err, utils.ErrStreamTimeout) {
+			t.Errorf("StreamSSE() error = %v, want %v", err, utils.ErrStreamTimeout)
+		}
+	})
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/x-ndjson" {
+			t.Errorf("Accept = %v", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprint(w, "{\"id\":1,\"name\":\"a\"}\n\n{\"id\":2,\"name\":\"b\"}\r\n{\"id\":3,\"name\":\"c\"}\n")
+	}))
+	defer srv.Close()
+
+	type record struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var names []string
+	curl := utils.NewCurl()
+	err := curl.StreamNDJSON(http.MethodPost, srv.URL, strings.NewReader(`{"q":1}`), utils.DecodeNDJSON(func(v *record) error {
+		names = append(names, v.Name)
+		if v.Id == 2 {
+			return utils.DONE
+		}
+		return nil
+	}))
+	if err != nil || strings.Join(names, ",") != "a,b" {
+		t.Errorf("StreamNDJSON() names = %v, error = %v", names, err)
+	}
+	if h := curl.GetHeader(); h.Get("Accept") != "" {
+		t.Errorf("GetHeader() = %v", h)
+	}
+}
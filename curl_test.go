@@ -1,16 +1,19 @@
 package utils_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Is999/go-utils"
 	"github.com/Is999/go-utils/errors"
@@ -723,6 +726,53 @@ func TestPostFile(t *testing.T) {
 	}
 }
 
+func TestSendContext(t *testing.T) {
+	// 启动http服务器: 慢接口
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(300 * time.Millisecond):
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr error
+	}{
+		{name: "001", timeout: 50 * time.Millisecond, wantErr: context.DeadlineExceeded},
+		{name: "002", timeout: 5 * time.Second, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			var requestId string
+			curl := utils.NewCurl(utils.WithCurlMaxRetry(3)).BeforeRequest(func(request *http.Request) error {
+				// 请求ID已写入context
+				requestId = utils.RequestIdFromContext(request.Context())
+				return nil
+			})
+
+			start := time.Now()
+			err := curl.GetContext(ctx, srv.URL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && time.Since(start) > 250*time.Millisecond {
+				t.Errorf("GetContext() 未及时取消, time spent %v", time.Since(start))
+			}
+			if requestId != curl.GetRequestId() {
+				t.Errorf("RequestIdFromContext() = %v, want %v", requestId, curl.GetRequestId())
+			}
+		})
+	}
+}
+
 type RespBody[T any] struct {
 	Success bool   `json:"success"`
 	Code    int    `json:"code"`
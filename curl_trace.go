@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestStats 单次 Send 的请求统计, 连接耗时取自最后一次请求(含重试时)
+//
+//	复用连接时 DNSLookup, TCPConnect, TLSHandshake 为0
+type RequestStats struct {
+	Method       string        // 请求方式
+	URL          string        // 请求地址(已脱敏)
+	StatusCode   int           // 响应状态码: 未收到响应时为0
+	Err          error         // 请求错误
+	RequestId    string        // 请求ID
+	Attempts     int           // 请求次数(含重试)
+	ConnReused   bool          // 是否复用连接
+	RemoteAddr   string        // 服务端地址
+	DNSLookup    time.Duration // DNS 解析耗时
+	TCPConnect   time.Duration // TCP 连接耗时
+	TLSHandshake time.Duration // TLS 握手耗时
+	TTFB         time.Duration // 从发送请求到收到第一个响应字节的耗时
+	Total        time.Duration // Send 总耗时(含重试等待、读取响应体)
+}
+
+// LogValue 实现 slog.LogValuer 接口, 如: logger.Info("http", "stats", stats)
+func (s RequestStats) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("method", s.Method),
+		slog.String("url", s.URL),
+		slog.Int("status_code", s.StatusCode),
+		slog.Int("attempts", s.Attempts),
+		slog.Bool("conn_reused", s.ConnReused),
+		slog.String("remote_addr", s.RemoteAddr),
+		slog.Duration("dns", s.DNSLookup),
+		slog.Duration("connect", s.TCPConnect),
+		slog.Duration("tls", s.TLSHandshake),
+		slog.Duration("ttfb", s.TTFB),
+		slog.Duration("total", s.Total),
+	}
+	if s.Err != nil {
+		attrs = append(attrs, slog.String("err", s.Err.Error()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// WithCurlObserver 设置请求统计回调方法, 每次 Send 完成后回调一次, 如记录 Prometheus 直方图
+func WithCurlObserver(observer func(stats RequestStats)) CurlOption {
+	return func(c *Curl) {
+		c.SetObserver(observer)
+	}
+}
+
+// SetObserver 设置请求统计回调方法, 每次 Send 完成后回调一次; 设置为nil取消
+//
+//	设置了回调方法或开启默认日志时, 使用 httptrace.ClientTrace 统计 DNS 解析、TCP 连接、TLS 握手、首字节耗时
+func (c *Curl) SetObserver(observer func(stats RequestStats)) *Curl {
+	c.observer = observer
+	return c
+}
+
+// observe 回调请求统计并记录 Debug 日志
+func (c *Curl) observe(req *http.Request, resp *http.Response, err error, st *sendState) {
+	stats := RequestStats{
+		Method:    req.Method,
+		URL:       c.redactor.URL(req.URL),
+		Err:       err,
+		RequestId: RequestIdFromContext(req.Context()),
+		Attempts:  st.attempts,
+		Total:     time.Since(st.start),
+	}
+	if resp != nil {
+		stats.StatusCode = resp.StatusCode
+	}
+	st.trace.fill(&stats)
+
+	// Debug 日志
+	if c.defLogOutput {
+		c.Logger.Debug("RequestStats", "stats", stats)
+	}
+
+	if c.observer != nil {
+		c.observer(stats)
+	}
+}
+
+// requestTrace 使用 httptrace.ClientTrace 统计请求各阶段耗时
+type requestTrace struct {
+	mu sync.Mutex
+
+	start, dnsStart, dnsDone, connStart, connDone, tlsStart, tlsDone, firstByte time.Time
+
+	reused     bool
+	remoteAddr string
+}
+
+// context 重置统计并返回携带 httptrace.ClientTrace 的 ctx
+func (t *requestTrace) context(ctx context.Context) context.Context {
+	t.mu.Lock()
+	t.start = time.Now()
+	t.dnsStart, t.dnsDone, t.connStart, t.connDone = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	t.tlsStart, t.tlsDone, t.firstByte = time.Time{}, time.Time{}, time.Time{}
+	t.reused, t.remoteAddr = false, ""
+	t.mu.Unlock()
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.set(&t.connStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.set(&t.connDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	})
+}
+
+// set 记录首次发生的时间, 如 Happy Eyeballs 同时建立多个连接时只记录第一个
+func (t *requestTrace) set(v *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if v.IsZero() {
+		*v = time.Now()
+	}
+}
+
+// fill 将各阶段耗时写入 stats
+func (t *requestTrace) fill(stats *RequestStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := func(start, end time.Time) time.Duration {
+		if start.IsZero() || end.IsZero() {
+			return 0
+		}
+		return end.Sub(start)
+	}
+	stats.ConnReused = t.reused
+	stats.RemoteAddr = t.remoteAddr
+	stats.TTFB = since(t.start, t.firstByte)
+
+	// 复用连接时 Transport 可能已在后台建立新连接, 其耗时不计入本次请求
+	if !t.reused {
+		stats.DNSLookup = since(t.dnsStart, t.dnsDone)
+		stats.TCPConnect = since(t.connStart, t.connDone)
+		stats.TLSHandshake = since(t.tlsStart, t.tlsDone)
+	}
+}
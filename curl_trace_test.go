@@ -0,0 +1,55 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestCurlObserver(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/none" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	var list []utils.RequestStats
+	curl := utils.NewCurl(
+		utils.WithCurlTransportPool(utils.NewTransportPool()),
+		utils.WithCurlInsecureSkipVerify(true),
+		utils.WithCurlObserver(func(stats utils.RequestStats) {
+			list = append(list, stats)
+		}),
+	)
+
+	for _, path := range []string{"/", "/", "/none"} {
+		_ = curl.Get(srv.URL + path)
+	}
+	if len(list) != 3 {
+		t.Fatalf("observer called %d times, want 3", len(list))
+	}
+
+	// 首次请求建立连接
+	first := list[0]
+	if first.ConnReused || first.TCPConnect <= 0 || first.TLSHandshake <= 0 || first.TTFB <= 0 || first.Total < first.TTFB {
+		t.Errorf("first stats = %+v", first)
+	}
+	if first.StatusCode != http.StatusOK || first.Attempts != 1 || first.Method != http.MethodGet || first.RequestId != curl.GetRequestId() || first.Err != nil {
+		t.Errorf("first stats = %+v", first)
+	}
+
+	// 复用连接
+	if second := list[1]; !second.ConnReused || second.TCPConnect != 0 || second.TLSHandshake != 0 {
+		t.Errorf("second stats = %+v", second)
+	}
+
+	// 请求失败
+	if third := list[2]; third.StatusCode != http.StatusNotFound || third.Err == nil {
+		t.Errorf("third stats = %+v", third)
+	}
+}
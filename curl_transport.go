@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// defaultTransportPool 默认共享的 TransportPool
+var defaultTransportPool = NewTransportPool()
+
+// DefaultTransportPool 获取默认共享的 TransportPool, 未设置 WithCurlTransportPool 的 Curl 使用该连接池
+func DefaultTransportPool() *TransportPool {
+	return defaultTransportPool
+}
+
+// TransportKey 共享 http.Transport 的代理及TLS配置, 配置相同的 Curl 共享同一个 http.Transport
+//
+//	证书以文件路径或内容作为标识, 证书文件内容变更后需调用 TransportPool.Remove 重新加载
+type TransportKey struct {
+	ProxyURL           string // 代理地址
+	InsecureSkipVerify bool   // 跳过https不安全验证
+	RootCAs            string // 根证书
+	Cert, Key          string // 证书, 秘钥
+}
+
+// TransportStats 连接池统计
+type TransportStats struct {
+	Transports int   // http.Transport 数量
+	Hits       int64 // 复用 http.Transport 次数
+	Misses     int64 // 创建 http.Transport 次数
+	Dials      int64 // 建立连接次数
+	OpenConns  int64 // 当前打开的连接数
+}
+
+// TransportOption TransportPool配置项
+type TransportOption func(*transportConfig)
+
+type transportConfig struct {
+	maxIdleConns          int
+	maxIdleConnsPerHost   int
+	maxConnsPerHost       int
+	idleConnTimeout       time.Duration
+	dialTimeout           time.Duration
+	keepAlive             time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	disableKeepAlives     bool
+	http2                 bool
+}
+
+// WithTransportMaxIdleConns 设置所有 Host 最大空闲连接数, 默认: 100
+func WithTransportMaxIdleConns(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.maxIdleConns = max(n, 0)
+	}
+}
+
+// WithTransportMaxIdleConnsPerHost 设置每个 Host 最大空闲连接数, 默认: 10
+func WithTransportMaxIdleConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.maxIdleConnsPerHost = max(n, 0)
+	}
+}
+
+// WithTransportMaxConnsPerHost 设置每个 Host 最大连接数, 默认: 0 不限制
+func WithTransportMaxConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.maxConnsPerHost = max(n, 0)
+	}
+}
+
+// WithTransportIdleConnTimeout 设置空闲连接超时时间, 默认: 90秒
+func WithTransportIdleConnTimeout(timeout time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.idleConnTimeout = max(timeout, 0)
+	}
+}
+
+// WithTransportDialTimeout 设置建立连接超时时间, 默认: 30秒
+func WithTransportDialTimeout(timeout time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.dialTimeout = max(timeout, 0)
+	}
+}
+
+// WithTransportKeepAlive 设置 TCP keep-alive 探测间隔, 默认: 30秒; 负数关闭 TCP keep-alive
+func WithTransportKeepAlive(keepAlive time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.keepAlive = keepAlive
+	}
+}
+
+// WithTransportTLSHandshakeTimeout 设置TLS握手超时时间, 默认: 10秒
+func WithTransportTLSHandshakeTimeout(timeout time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.tlsHandshakeTimeout = max(timeout, 0)
+	}
+}
+
+// WithTransportResponseHeaderTimeout 设置等待响应头超时时间, 默认: 0 不限制(受 Curl 超时时间限制)
+func WithTransportResponseHeaderTimeout(timeout time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.responseHeaderTimeout = max(timeout, 0)
+	}
+}
+
+// WithTransportDisableKeepAlives 设置是否禁用 HTTP keep-alive(每个请求使用新连接), 默认: false
+func WithTransportDisableKeepAlives(disable bool) TransportOption {
+	return func(c *transportConfig) {
+		c.disableKeepAlives = disable
+	}
+}
+
+// WithTransportHTTP2 设置是否启用 HTTP/2, 默认: true
+func WithTransportHTTP2(enable bool) TransportOption {
+	return func(c *transportConfig) {
+		c.http2 = enable
+	}
+}
+
+// TransportPool 按代理及TLS配置共享 http.Transport, 复用连接及TLS会话
+type TransportPool struct {
+	mu sync.Mutex
+
+	cfg transportConfig
+
+	transports map[TransportKey]*http.Transport
+
+	hits, misses, dials, openConns atomic.Int64
+}
+
+// NewTransportPool 实例化TransportPool
+func NewTransportPool(opts ...TransportOption) *TransportPool {
+	p := &TransportPool{
+		cfg: transportConfig{
+			maxIdleConns:        100,
+			maxIdleConnsPerHost: 10,
+			idleConnTimeout:     90 * time.Second,
+			dialTimeout:         30 * time.Second,
+			keepAlive:           30 * time.Second,
+			tlsHandshakeTimeout: 10 * time.Second,
+			http2:               true,
+		},
+		transports: make(map[TransportKey]*http.Transport),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&p.cfg)
+		}
+	}
+	return p
+}
+
+// Transport 获取 key 对应的 http.Transport, 不存在时创建
+func (p *TransportPool) Transport(key TransportKey) (*http.Transport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tr, ok := p.transports[key]; ok {
+		p.hits.Add(1)
+		return tr, nil
+	}
+
+	tr, err := p.newTransport(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	p.misses.Add(1)
+	p.transports[key] = tr
+	return tr, nil
+}
+
+// Remove 关闭 key 对应的 http.Transport 的空闲连接并从连接池移除
+func (p *TransportPool) Remove(key TransportKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if tr, ok := p.transports[key]; ok {
+		tr.CloseIdleConnections()
+		delete(p.transports, key)
+	}
+}
+
+// CloseIdleConnections 关闭所有 http.Transport 的空闲连接
+func (p *TransportPool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tr := range p.transports {
+		tr.CloseIdleConnections()
+	}
+}
+
+// Stats 获取连接池统计
+func (p *TransportPool) Stats() TransportStats {
+	p.mu.Lock()
+	n := len(p.transports)
+	p.mu.Unlock()
+	return TransportStats{
+		Transports: n,
+		Hits:       p.hits.Load(),
+		Misses:     p.misses.Load(),
+		Dials:      p.dials.Load(),
+		OpenConns:  p.openConns.Load(),
+	}
+}
+
+// newTransport 创建 http.Transport
+func (p *TransportPool) newTransport(key TransportKey) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   p.cfg.dialTimeout,
+		KeepAlive: p.cfg.keepAlive,
+	}
+
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			p.dials.Add(1)
+			p.openConns.Add(1)
+			return &countConn{Conn: conn, open: &p.openConns}, nil
+		},
+		MaxIdleConns:          p.cfg.maxIdleConns,
+		MaxIdleConnsPerHost:   p.cfg.maxIdleConnsPerHost,
+		MaxConnsPerHost:       p.cfg.maxConnsPerHost,
+		IdleConnTimeout:       p.cfg.idleConnTimeout,
+		TLSHandshakeTimeout:   p.cfg.tlsHandshakeTimeout,
+		ResponseHeaderTimeout: p.cfg.responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     p.cfg.disableKeepAlives,
+		ForceAttemptHTTP2:     p.cfg.http2,
+		TLSClientConfig: &tls.Config{
+			// TLS会话复用
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		},
+	}
+
+	// 禁用 HTTP/2
+	if !p.cfg.http2 {
+		tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	// 设置代理
+	if len(key.ProxyURL) > 0 {
+		if err := ProxyURL(tr, key.ProxyURL); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	// 跳过https不安全验证
+	if key.InsecureSkipVerify {
+		tr.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	// 根证书
+	if len(key.RootCAs) > 0 {
+		if err := RootCAs(tr.TLSClientConfig, key.RootCAs); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	// 证书
+	if len(key.Cert) > 0 && len(key.Key) > 0 {
+		if err := Certificate(tr.TLSClientConfig, key.Cert, key.Key); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
+	return tr, nil
+}
+
+// countConn 统计打开的连接数
+type countConn struct {
+	net.Conn
+	open *atomic.Int64
+	once sync.Once
+}
+
+// Close 实现 net.Conn 接口
+func (c *countConn) Close() error {
+	c.once.Do(func() {
+		c.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
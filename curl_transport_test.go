@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestTransportPool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Json(w).Success(10000, "ok")
+	}))
+	defer srv.Close()
+
+	pool := utils.NewTransportPool(
+		utils.WithTransportMaxIdleConnsPerHost(4),
+		utils.WithTransportDialTimeout(time.Second),
+		utils.WithTransportHTTP2(false),
+	)
+
+	// 多个 Curl 共享 Transport 及连接
+	for range 3 {
+		if err := utils.NewCurl(utils.WithCurlTransportPool(pool)).Get(srv.URL); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	// 连接异步放回空闲连接池, 连接数只校验范围
+	stats := pool.Stats()
+	if stats.Transports != 1 || stats.Misses != 1 || stats.Hits != 2 || stats.Dials < 1 || stats.Dials > 3 || stats.OpenConns < 1 || stats.OpenConns > stats.Dials {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	dials := stats.Dials
+
+	// 配置不同的 Curl 使用不同的 Transport; 配置变更后获取新的 Transport
+	curl := utils.NewCurl(utils.WithCurlTransportPool(pool))
+	if err := curl.InsecureSkipVerify(true).Get(srv.URL); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := curl.InsecureSkipVerify(false).Get(srv.URL); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stats = pool.Stats(); stats.Transports != 2 || stats.Misses != 2 || stats.Dials <= dials {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// 等待连接放回空闲连接池后关闭
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if pool.CloseIdleConnections(); pool.Stats().OpenConns == 0 {
+			break
+		}
+	}
+	if stats = pool.Stats(); stats.OpenConns != 0 {
+		t.Errorf("Stats().OpenConns = %d, want 0", stats.OpenConns)
+	}
+
+	// 无效代理地址
+	if err := utils.NewCurl(utils.WithCurlTransportPool(pool), utils.WithCurlProxyURL("://bad")).Get(srv.URL); err == nil {
+		t.Errorf("Get() error = nil, want proxy error")
+	}
+}
@@ -0,0 +1,317 @@
+// Package curltest 提供 utils.Curl 的测试工具: 可编程的模拟 http.RoundTripper 及请求录制/回放
+//
+//	通过 utils.WithCurlTransport(mock) 或 Curl.BeforeClient 设置 Client.Transport 使用
+package curltest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+// Mock 模拟 http.RoundTripper, 按添加顺序匹配路由并返回预设响应
+type Mock struct {
+	mu sync.Mutex
+
+	// 路由
+	routes []*Route
+
+	// 未匹配到路由时使用的 RoundTripper: nil 返回错误
+	fallback http.RoundTripper
+
+	// 未匹配到路由的请求
+	unmatched []string
+}
+
+// NewMock 实例化Mock
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// On 添加路由
+//
+//	method 请求方式: 为空匹配所有请求方式
+//	path 请求路径: 为空匹配所有路径
+func (m *Mock) On(method, path string) *Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &Route{
+		m:      m,
+		method: strings.ToUpper(method),
+		path:   path,
+		query:  make(url.Values),
+		header: make(http.Header),
+		times:  -1,
+		reply:  &reply{statusCode: http.StatusOK, header: make(http.Header)},
+	}
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// Fallback 设置未匹配到路由时使用的 RoundTripper, 如 http.DefaultTransport 或 Recorder
+func (m *Mock) Fallback(rt http.RoundTripper) *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = rt
+	return m
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (m *Mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	m.mu.Lock()
+	var route *Route
+	for _, r := range m.routes {
+		if r.match(req, body) {
+			route = r
+			break
+		}
+	}
+	if route == nil {
+		m.unmatched = append(m.unmatched, req.Method+" "+req.URL.String())
+		fallback := m.fallback
+		m.mu.Unlock()
+		if fallback != nil {
+			return fallback.RoundTrip(req)
+		}
+		return nil, errors.Errorf("curltest: no route matched %s %s", req.Method, req.URL.String())
+	}
+	route.calls++
+	m.mu.Unlock()
+
+	return route.reply.response(req)
+}
+
+// Unmatched 获取未匹配到路由的请求: "METHOD URL"
+func (m *Mock) Unmatched() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.unmatched...)
+}
+
+// AssertExpectations 断言: 设置了 Times 的路由调用次数符合预期, 且没有未匹配到路由的请求
+func (m *Mock) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, r := range m.routes {
+		if r.times >= 0 && r.calls != r.times {
+			t.Errorf("curltest: %s called %d times, want %d", r, r.calls, r.times)
+			ok = false
+		}
+	}
+	for _, u := range m.unmatched {
+		t.Errorf("curltest: unmatched request %s", u)
+		ok = false
+	}
+	return ok
+}
+
+// Route 路由: 匹配条件及预设响应
+type Route struct {
+	m            *Mock
+	method, path string
+	query        url.Values
+	header       http.Header
+	body         func(body []byte) bool
+
+	// 预期调用次数: -1 不限制
+	times int
+
+	// 实际调用次数
+	calls int
+
+	reply *reply
+}
+
+// reply 预设响应
+type reply struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+	f          func(req *http.Request) (*http.Response, error)
+}
+
+// String 实现 fmt.Stringer 接口
+func (r *Route) String() string {
+	s := utils.Ternary(r.method == "", "*", r.method) + " " + utils.Ternary(r.path == "", "*", r.path)
+	if len(r.query) > 0 {
+		s += "?" + r.query.Encode()
+	}
+	return s
+}
+
+// Query 匹配url参数, 请求中包含该参数且值相同
+func (r *Route) Query(key, value string) *Route {
+	r.query.Add(key, value)
+	return r
+}
+
+// Header 匹配请求头, 请求中包含该请求头且值相同
+func (r *Route) Header(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// Body 匹配请求体, 与请求体完全相同
+func (r *Route) Body(body string) *Route {
+	return r.BodyFunc(func(b []byte) bool {
+		return string(b) == body
+	})
+}
+
+// BodyContains 匹配请求体, 请求体包含 sub
+func (r *Route) BodyContains(sub string) *Route {
+	return r.BodyFunc(func(b []byte) bool {
+		return bytes.Contains(b, []byte(sub))
+	})
+}
+
+// BodyFunc 使用 f 匹配请求体
+func (r *Route) BodyFunc(f func(body []byte) bool) *Route {
+	r.body = f
+	return r
+}
+
+// Times 设置预期调用次数, 达到次数后不再匹配该路由; 由 Mock.AssertExpectations 断言
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 预期调用1次
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Calls 获取实际调用次数
+func (r *Route) Calls() int {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return r.calls
+}
+
+// Reply 设置响应状态码及响应体
+func (r *Route) Reply(statusCode int, body string) *Route {
+	r.reply.statusCode = statusCode
+	r.reply.body = []byte(body)
+	return r
+}
+
+// ReplyJSON 设置响应状态码及JSON响应体, 使用 utils.Marshal 编码
+func (r *Route) ReplyJSON(statusCode int, v any) *Route {
+	body, err := utils.Marshal(v)
+	if err != nil {
+		r.reply.err = errors.Wrap(err)
+		return r
+	}
+	r.reply.statusCode = statusCode
+	r.reply.body = body
+	r.reply.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// ReplyHeader 设置响应头
+func (r *Route) ReplyHeader(key, value string) *Route {
+	r.reply.header.Add(key, value)
+	return r
+}
+
+// ReplyError 返回传输错误, 模拟网络异常
+func (r *Route) ReplyError(err error) *Route {
+	r.reply.err = err
+	return r
+}
+
+// ReplyFunc 使用 f 生成响应
+func (r *Route) ReplyFunc(f func(req *http.Request) (*http.Response, error)) *Route {
+	r.reply.f = f
+	return r
+}
+
+// match 判断请求是否匹配路由, 调用方需持有锁
+func (r *Route) match(req *http.Request, body []byte) bool {
+	if r.times >= 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != req.Method {
+		return false
+	}
+	if r.path != "" && r.path != req.URL.Path {
+		return false
+	}
+
+	query := req.URL.Query()
+	for k, vs := range r.query {
+		for _, v := range vs {
+			if !utils.IsHas(v, query[k]) {
+				return false
+			}
+		}
+	}
+	for k, vs := range r.header {
+		for _, v := range vs {
+			if !utils.IsHas(v, req.Header.Values(k)) {
+				return false
+			}
+		}
+	}
+	if r.body != nil && !r.body(body) {
+		return false
+	}
+	return true
+}
+
+// response 生成响应
+func (p *reply) response(req *http.Request) (*http.Response, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.f != nil {
+		return p.f(req)
+	}
+	return newResponse(req, p.statusCode, p.header, p.body), nil
+}
+
+// newResponse 生成响应
+func newResponse(req *http.Request, statusCode int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// readBody 读取请求体, 读取后重置请求体
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, rc, err := utils.DrainBody(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	req.Body = rc
+	return body, nil
+}
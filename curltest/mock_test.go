@@ -0,0 +1,60 @@
+package curltest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/curltest"
+	"github.com/Is999/go-utils/errors"
+)
+
+func TestMock(t *testing.T) {
+	mock := curltest.NewMock()
+	user := mock.On(http.MethodGet, "/user").Query("id", "1").ReplyJSON(http.StatusOK, map[string]any{"id": 1, "name": "tom"}).Once()
+	create := mock.On(http.MethodPost, "/user").BodyContains(`"name":"jerry"`).Reply(http.StatusCreated, `{"id":2}`).Times(2)
+	mock.On("", "/down").ReplyError(errors.New("connection refused"))
+
+	// GET 匹配url参数
+	var got struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	curl := utils.NewCurl(utils.WithCurlTransport(mock))
+	if err := curl.SetParam("id", "1").GetJSON("http://api.test/user", &got); err != nil {
+		t.Fatalf("GetJSON() error = %v", err)
+	}
+	if got.Id != 1 || got.Name != "tom" {
+		t.Errorf("GetJSON() got = %+v", got)
+	}
+
+	// POST 匹配请求体, 通过 BeforeClient 设置 Transport
+	for range 2 {
+		curl = utils.NewCurl().SetStatusCode(http.StatusCreated).BeforeClient(func(cli *http.Client) error {
+			cli.Transport = mock
+			return nil
+		})
+		if err := curl.SetBody(strings.NewReader(`{"name":"jerry"}`)).Post("http://api.test/user"); err != nil {
+			t.Errorf("Post() error = %v", err)
+		}
+	}
+
+	// 模拟网络异常
+	if err := utils.NewCurl(utils.WithCurlTransport(mock)).Get("http://api.test/down"); err == nil {
+		t.Errorf("Get() error = nil, want connection refused")
+	}
+
+	if user.Calls() != 1 || create.Calls() != 2 {
+		t.Errorf("Calls() = %d, %d, want 1, 2", user.Calls(), create.Calls())
+	}
+	mock.AssertExpectations(t)
+
+	// 超过预期调用次数不再匹配
+	if err := utils.NewCurl(utils.WithCurlTransport(mock), utils.WithCurlMaxRetry(1)).SetParam("id", "1").Get("http://api.test/user"); err == nil {
+		t.Errorf("Get() error = nil, want no route matched")
+	}
+	if got := mock.Unmatched(); len(got) != 1 {
+		t.Errorf("Unmatched() = %v, want 1 request", got)
+	}
+}
@@ -0,0 +1,263 @@
+package curltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/errors"
+)
+
+// Mode 录制/回放模式
+type Mode int8
+
+const (
+	ModeReplay Mode = iota // 0 回放: 只使用 fixture 文件中的记录, 未找到记录返回错误
+	ModeRecord             // 1 录制: 发送真实请求并将记录写入 fixture 文件(覆盖已有文件)
+	ModeAuto               // 2 自动: fixture 文件存在时回放, 否则录制
+)
+
+// Exchange 一次请求及响应的记录
+type Exchange struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 请求记录, Body 在 fixture 文件中以 base64 保存, 二进制内容不会损坏
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// RecordedResponse 响应记录, Body 在 fixture 文件中以 base64 保存
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// Recorder 录制真实请求到 JSON fixture 文件, 或从 fixture 文件回放响应
+//
+//	回放时按请求方式、URL、请求体匹配记录, 同一请求多次录制时按顺序回放, 用完后重复回放最后一条
+type Recorder struct {
+	mu sync.Mutex
+
+	// fixture 文件路径
+	file string
+
+	// 录制/回放模式
+	mode Mode
+
+	// 录制时发送真实请求的 RoundTripper
+	transport http.RoundTripper
+
+	// 录制时不保存的请求头、响应头
+	skipHeaders []string
+
+	// 录制时的脱敏规则
+	redactor *utils.Redactor
+
+	// 记录
+	exchanges []*Exchange
+
+	// 回放时记录是否已使用
+	used []bool
+}
+
+// RecorderOption Recorder配置项
+type RecorderOption func(*Recorder)
+
+// WithRecorderTransport 设置录制时发送真实请求的 RoundTripper, 默认: http.DefaultTransport
+func WithRecorderTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		if rt != nil {
+			r.transport = rt
+		}
+	}
+}
+
+// WithRecorderSkipHeaders 设置录制时不保存的请求头、响应头, 默认: Authorization, Cookie, Set-Cookie, X-Signature
+func WithRecorderSkipHeaders(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.skipHeaders = keys
+	}
+}
+
+// WithRecorderRedactor 设置录制时 url、头信息、请求体、响应体的脱敏规则, nil 不脱敏
+//
+//	默认: utils.DefaultRedactor() 的规则, 并隐藏JSON及表单中的 access_token, refresh_token, id_token, client_secret, password 字段
+//	回放时按脱敏后的 url、请求体匹配记录
+func WithRecorderRedactor(redactor *utils.Redactor) RecorderOption {
+	return func(r *Recorder) {
+		r.redactor = redactor
+	}
+}
+
+// defaultRedactor 录制时默认的脱敏规则
+var defaultRedactor = utils.NewRedactor(
+	utils.WithRedactJSONPaths("**.access_token", "**.refresh_token", "**.id_token", "**.client_secret", "**.password"),
+	utils.WithRedactFormKeys("access_token", "refresh_token", "id_token", "client_secret", "password"),
+)
+
+// NewRecorder 实例化Recorder, 回放模式下加载 fixture 文件
+//
+//	file fixture 文件路径, 如: testdata/fixtures/user.json
+//	mode 录制/回放模式: ModeReplay, ModeRecord, ModeAuto
+func NewRecorder(file string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		file:        file,
+		mode:        mode,
+		transport:   http.DefaultTransport,
+		skipHeaders: []string{"Authorization", "Cookie", "Set-Cookie", "X-Signature"},
+		redactor:    defaultRedactor,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+
+	if r.mode == ModeAuto {
+		if _, err := os.Stat(file); err == nil {
+			r.mode = ModeReplay
+		} else {
+			r.mode = ModeRecord
+		}
+	}
+
+	if r.mode == ModeReplay {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if err = json.Unmarshal(b, &r.exchanges); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		r.used = make([]bool, len(r.exchanges))
+	}
+	return r, nil
+}
+
+// Mode 获取实际的录制/回放模式, ModeAuto 已解析为 ModeReplay 或 ModeRecord
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Exchanges 获取记录
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Exchange, 0, len(r.exchanges))
+	for _, e := range r.exchanges {
+		list = append(list, *e)
+	}
+	return list
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// replay 回放响应
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, body := r.url(req), r.redactor.Body(req.Header.Get("Content-Type"), body)
+	last := -1
+	for i, e := range r.exchanges {
+		if e.Request.Method != req.Method || e.Request.URL != url || !bytes.Equal(e.Request.Body, body) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return newResponse(req, e.Response.StatusCode, e.Response.Header, e.Response.Body), nil
+		}
+		last = i
+	}
+	if last >= 0 {
+		e := r.exchanges[last]
+		return newResponse(req, e.Response.StatusCode, e.Response.Header, e.Response.Body), nil
+	}
+	return nil, errors.Errorf("curltest: no fixture matched %s %s in %s", req.Method, req.URL.String(), r.file)
+}
+
+// record 发送真实请求并写入 fixture 文件
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, &Exchange{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    r.url(req),
+			Header: r.header(req.Header),
+			Body:   r.redactor.Body(req.Header.Get("Content-Type"), body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.header(resp.Header),
+			Body:       r.redactor.Body(resp.Header.Get("Content-Type"), respBody),
+		},
+	})
+
+	if err = r.save(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return resp, nil
+}
+
+// url 脱敏后的请求 url
+func (r *Recorder) url(req *http.Request) string {
+	if r.redactor == nil {
+		return req.URL.String()
+	}
+	return r.redactor.URL(req.URL)
+}
+
+// header 删除不保存的头信息并脱敏
+func (r *Recorder) header(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range r.skipHeaders {
+		h.Del(k)
+	}
+	return r.redactor.Header(h)
+}
+
+// save 写入 fixture 文件, 调用方需持有锁
+func (r *Recorder) save() error {
+	b, err := json.MarshalIndent(r.exchanges, "", "  ")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(os.WriteFile(r.file, b, 0644))
+}
@@ -0,0 +1,127 @@
+package curltest_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Is999/go-utils"
+	"github.com/Is999/go-utils/curltest"
+)
+
+func TestRecorder(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		utils.Json(w).Success(10000, r.URL.Query().Get("n"))
+	}))
+	fixture := filepath.Join(t.TempDir(), "fixtures", "api.json")
+
+	get := func(rt http.RoundTripper, n string) string {
+		var body string
+		err := utils.NewCurl(utils.WithCurlTransport(rt)).SetParam("n", n).SetHeader("Authorization", "Bearer token").
+			AfterBody(func(b []byte) error {
+				body = string(b)
+				return nil
+			}).Get(srv.URL + "/api")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return body
+	}
+
+	// 录制
+	rec, err := curltest.NewRecorder(fixture, curltest.ModeAuto)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if rec.Mode() != curltest.ModeRecord {
+		t.Fatalf("Mode() = %v, want %v", rec.Mode(), curltest.ModeRecord)
+	}
+	want1, want2 := get(rec, "1"), get(rec, "2")
+	if got := rec.Exchanges(); len(got) != 2 || got[0].Request.Header.Get("Authorization") != "" {
+		t.Errorf("Exchanges() = %+v", got)
+	}
+	srv.Close()
+
+	// 回放: 服务已关闭
+	rec, err = curltest.NewRecorder(fixture, curltest.ModeAuto)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if rec.Mode() != curltest.ModeReplay {
+		t.Fatalf("Mode() = %v, want %v", rec.Mode(), curltest.ModeReplay)
+	}
+	if got := get(rec, "2"); got != want2 {
+		t.Errorf("replay got = %v, want %v", got, want2)
+	}
+	if got := get(rec, "1"); got != want1 {
+		t.Errorf("replay got = %v, want %v", got, want1)
+	}
+	if hits != 2 {
+		t.Errorf("hits = %d, want 2", hits)
+	}
+
+	// 未录制的请求
+	err = utils.NewCurl(utils.WithCurlTransport(rec)).SetParam("n", "3").Get(srv.URL + "/api")
+	if err == nil {
+		t.Errorf("Get() error = nil, want no fixture matched")
+	}
+}
+
+func TestRecorderRedact(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x80}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"secret-token","token_type":"Bearer"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(binary)
+	}))
+	defer srv.Close()
+	fixture := filepath.Join(t.TempDir(), "api.json")
+
+	send := func(rt http.RoundTripper, path string, body []byte) []byte {
+		var got []byte
+		err := utils.NewCurl(utils.WithCurlTransport(rt)).
+			AfterBody(func(b []byte) error {
+				got = b
+				return nil
+			}).Send(http.MethodPost, srv.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return got
+	}
+
+	rec, err := curltest.NewRecorder(fixture, curltest.ModeRecord)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	send(rec, "/token", []byte("grant_type=client_credentials"))
+	send(rec, "/bin", binary)
+
+	// fixture 文件不包含敏感信息
+	b, _ := os.ReadFile(fixture)
+	for _, s := range []string{"secret-token", "secret-session", "Set-Cookie"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("fixture 包含 %v", s)
+		}
+	}
+
+	// 回放: 二进制请求体、响应体不损坏
+	rec, err = curltest.NewRecorder(fixture, curltest.ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if got := send(rec, "/bin", binary); !bytes.Equal(got, binary) {
+		t.Errorf("replay got = %x, want %x", got, binary)
+	}
+}
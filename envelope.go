@@ -0,0 +1,395 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// 密文信封: 自描述的密文格式, 记录解密所需的密钥ID、加密模式、IV等信息, 轮换密钥或更换加密模式后仍可解密历史数据
+//
+//	格式: 版本(1字节) | 密钥类型(1字节) | 加密模式(1字节) | 填充方式(1字节) | 标记(1字节) | 密钥ID长度(1字节) | 密钥ID | IV长度(1字节) | IV | 密文 | MAC(32字节, 可选)
+//	 - 填充方式: 0 不填充, 1 PKCS7
+//	 - 标记: 第1位为1时密文末尾有MAC
+//	 - GCM模式: IV为nonce, IV之前的头部作为附加数据参与认证
+//	 - MAC: HMAC-SHA256(macKey, MAC之前的全部内容), macKey = HMAC-SHA256(秘钥, "envelope-mac")
+//	 - RSA: 使用 OAEP(SHA256) 分段加密, 无IV及MAC, 加密模式为0
+
+const (
+	// envelopeVersion 信封版本
+	envelopeVersion byte = 1
+
+	// envelopeFlagMAC 标记: 密文末尾有MAC
+	envelopeFlagMAC byte = 1 << 0
+
+	// envelopeHeaderSize 信封头部固定部分长度
+	envelopeHeaderSize = 6
+)
+
+// ErrEnvelopeInvalid 密文信封格式错误或MAC验证失败
+var ErrEnvelopeInvalid = errors.New("invalid envelope")
+
+// Envelope 密文信封
+type Envelope struct {
+	Version byte       // 版本
+	Type    KeyType    // 密钥类型
+	Mode    McryptMode // 加密模式
+	Padded  bool       // 明文使用 PKCS7 填充
+	KeyID   string     // 密钥ID
+	IV      []byte     // IV 或 GCM nonce
+	Payload []byte     // 密文
+	MAC     []byte     // HMAC-SHA256: 为nil时无MAC
+}
+
+// ParseEnvelope 解析密文信封, 不解密; 可用于查看密文使用的密钥ID及加密模式
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < envelopeHeaderSize {
+		return nil, errors.Wrap(ErrEnvelopeInvalid, "密文太短")
+	}
+	if data[0] != envelopeVersion {
+		return nil, errors.Wrapf(ErrEnvelopeInvalid, "不支持的版本: %d", data[0])
+	}
+
+	e := &Envelope{
+		Version: data[0],
+		Type:    KeyType(data[1]),
+		Mode:    McryptMode(data[2]),
+		Padded:  data[3] == 1,
+	}
+	flags := data[4]
+
+	// 密钥ID
+	rest := data[envelopeHeaderSize-1:]
+	id, rest, ok := cutLengthPrefixed(rest)
+	if !ok || len(id) == 0 {
+		return nil, errors.Wrap(ErrEnvelopeInvalid, "密钥ID错误")
+	}
+	e.KeyID = string(id)
+
+	// IV
+	if e.IV, rest, ok = cutLengthPrefixed(rest); !ok {
+		return nil, errors.Wrap(ErrEnvelopeInvalid, "IV错误")
+	}
+
+	// MAC
+	if flags&envelopeFlagMAC != 0 {
+		if len(rest) < sha256.Size {
+			return nil, errors.Wrap(ErrEnvelopeInvalid, "MAC错误")
+		}
+		rest, e.MAC = rest[:len(rest)-sha256.Size], rest[len(rest)-sha256.Size:]
+	}
+	e.Payload = rest
+	return e, nil
+}
+
+// cutLengthPrefixed 读取1字节长度前缀的内容
+func cutLengthPrefixed(data []byte) (value, rest []byte, ok bool) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, nil, false
+	}
+	n := 1 + int(data[0])
+	return data[1:n], data[n:], true
+}
+
+// header 信封头部: IV之前的内容, GCM模式作为附加数据
+func (e *Envelope) header() []byte {
+	b := make([]byte, 0, envelopeHeaderSize+len(e.KeyID))
+	b = append(b, e.Version, byte(e.Type), byte(e.Mode), Ternary[byte](e.Padded, 1, 0), Ternary(e.MAC != nil, envelopeFlagMAC, 0))
+	b = append(b, byte(len(e.KeyID)))
+	return append(b, e.KeyID...)
+}
+
+// body 信封MAC之前的内容
+func (e *Envelope) body() []byte {
+	b := e.header()
+	b = append(b, byte(len(e.IV)))
+	b = append(b, e.IV...)
+	return append(b, e.Payload...)
+}
+
+// Bytes 序列化密文信封
+func (e *Envelope) Bytes() []byte {
+	return append(e.body(), e.MAC...)
+}
+
+// mac 计算MAC
+func (e *Envelope) mac(secret string) []byte {
+	k := hmac.New(sha256.New, []byte(secret))
+	k.Write([]byte("envelope-mac"))
+	h := hmac.New(sha256.New, k.Sum(nil))
+	h.Write(e.body())
+	return h.Sum(nil)
+}
+
+// SealOption Seal配置项
+type SealOption func(*sealOptions)
+
+type sealOptions struct {
+	keyID string
+	mode  *McryptMode
+	mac   bool
+}
+
+// WithSealKey 使用指定ID的密钥加密, 默认使用当前密钥
+func WithSealKey(id string) SealOption {
+	return func(o *sealOptions) {
+		o.keyID = id
+	}
+}
+
+// WithSealMode 设置对称加密模式, 默认: AES 使用 GCM, DES 使用 CBC
+func WithSealMode(mode McryptMode) SealOption {
+	return func(o *sealOptions) {
+		o.mode = &mode
+	}
+}
+
+// WithSealMAC 设置非GCM模式是否添加MAC防止密文被篡改, 默认: true
+//
+//	不添加MAC的密文需使用 WithOpenAllowUnauthenticated(true) 解密
+func WithSealMAC(mac bool) SealOption {
+	return func(o *sealOptions) {
+		o.mac = mac
+	}
+}
+
+// Seal 使用当前密钥加密并封装为密文信封
+func (kr *Keyring) Seal(data []byte, opts ...SealOption) ([]byte, error) {
+	o := sealOptions{mac: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	var (
+		key *Key
+		err error
+	)
+	if o.keyID == "" {
+		key, err = kr.Active()
+	} else {
+		key, err = kr.Key(o.keyID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	e := &Envelope{Version: envelopeVersion, Type: key.Type, KeyID: key.ID}
+	if key.Type == KeyRSA {
+		payload, err := key.RSA.EncryptOAEP(string(data), rawEncode, sha256.New())
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		e.Payload = []byte(payload)
+		return e.Bytes(), nil
+	}
+
+	e.Mode = Ternary(key.Type == KeyAES, GCM, CBC)
+	if o.mode != nil {
+		e.Mode = *o.mode
+	}
+	if err = e.seal(key, data, o.mac); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return e.Bytes(), nil
+}
+
+// seal 对称加密
+func (e *Envelope) seal(key *Key, data []byte, mac bool) (err error) {
+	c, err := key.Cipher()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	switch e.Mode {
+	case GCM:
+		encrypt, err := c.EncryptGCM(data, e.header())
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		// 密文头部12字节为 nonce
+		e.IV, e.Payload = encrypt[:12], encrypt[12:]
+		return nil
+	case ECB:
+		e.Payload, err = c.EncryptECB(data, Pkcs7Padding)
+	case CBC, CTR, CFB, OFB:
+		// 随机生成IV
+		iv := make([]byte, c.block.BlockSize())
+		if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+			return errors.Wrap(err)
+		}
+		if err = c.setIV(string(iv)); err != nil {
+			return errors.Wrap(err)
+		}
+		e.IV = iv
+		switch e.Mode {
+		case CBC:
+			e.Payload, err = c.EncryptCBC(data, Pkcs7Padding)
+		case CTR:
+			e.Payload, err = c.EncryptCTR(data, Pkcs7Padding)
+		case CFB:
+			e.Payload, err = c.EncryptCFB(data, Pkcs7Padding)
+		default:
+			e.Payload, err = c.EncryptOFB(data, Pkcs7Padding)
+		}
+	default:
+		return errors.New("错误的加密模式")
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	e.Padded = true
+	if mac {
+		// 先标记有MAC, 使参与计算的头部包含MAC标记
+		e.MAC = []byte{}
+		e.MAC = e.mac(key.Secret)
+	}
+	return nil
+}
+
+// OpenOption Open配置项
+type OpenOption func(*openOptions)
+
+type openOptions struct {
+	unauthenticated bool
+}
+
+// WithOpenAllowUnauthenticated 设置是否允许解密未经认证(非GCM且无MAC)的对称加密密文, 默认: false
+//
+//	仅用于解密 WithSealMAC(false) 生成的历史数据: 未经认证的密文可被篡改, 且 CBC 模式存在填充预言攻击
+func WithOpenAllowUnauthenticated(allow bool) OpenOption {
+	return func(o *openOptions) {
+		o.unauthenticated = allow
+	}
+}
+
+// Open 解析密文信封, 按密钥ID选择密钥解密
+//
+//	密文经过认证(GCM、MAC、RSA)时, 指定ID的密钥不存在或解密失败后依次尝试其它已生效的同类型密钥
+//	未经认证的对称加密密文(如去掉MAC的密文)返回 ErrEnvelopeInvalid, 见 WithOpenAllowUnauthenticated
+func (kr *Keyring) Open(sealed []byte, opts ...OpenOption) ([]byte, error) {
+	var o openOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	e, err := ParseEnvelope(sealed)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if !e.authenticated() && !o.unauthenticated {
+		return nil, errors.Wrap(ErrEnvelopeInvalid, "密文未经认证(无MAC)")
+	}
+
+	keys := kr.candidates(e.KeyID, func(k *Key) bool {
+		return k.Type == e.Type && (k.ID == e.KeyID || e.authenticated())
+	})
+	if len(keys) == 0 {
+		return nil, errors.Wrapf(ErrKeyNotFound, "id=%s type=%s", e.KeyID, e.Type)
+	}
+
+	for _, key := range keys {
+		var data []byte
+		if data, err = e.open(key); err == nil {
+			return data, nil
+		}
+	}
+	return nil, errors.Wrap(err)
+}
+
+// authenticated 密文是否经过认证, 使用错误的密钥解密时会失败
+func (e *Envelope) authenticated() bool {
+	return e.Type == KeyRSA || e.Mode == GCM || e.MAC != nil
+}
+
+// open 使用密钥解密
+func (e *Envelope) open(key *Key) (data []byte, err error) {
+	if key.Type == KeyRSA {
+		decrypt, err := key.RSA.DecryptOAEP(string(e.Payload), rawDecode, sha256.New())
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return []byte(decrypt), nil
+	}
+
+	// 验证MAC
+	if e.MAC != nil && !hmac.Equal(e.MAC, e.mac(key.Secret)) {
+		return nil, errors.Wrap(ErrEnvelopeInvalid, "MAC验证失败")
+	}
+
+	c, err := key.Cipher()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	unPadding := Ternary[UnPadding](e.Padded, Pkcs7UnPadding, func(b []byte) ([]byte, error) { return b, nil })
+	switch e.Mode {
+	case GCM:
+		return c.DecryptGCM(append(bytes.Clone(e.IV), e.Payload...), e.header())
+	case ECB:
+		if len(e.Payload)%c.block.BlockSize() != 0 {
+			return nil, errors.New("密文不是块大小的倍数")
+		}
+		return c.DecryptECB(e.Payload, unPadding)
+	case CBC, CTR, CFB, OFB:
+		if err = c.setIV(string(e.IV)); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		switch e.Mode {
+		case CBC:
+			return c.DecryptCBC(e.Payload, unPadding)
+		case CTR:
+			return c.DecryptCTR(e.Payload, unPadding)
+		case CFB:
+			return c.DecryptCFB(e.Payload, unPadding)
+		default:
+			return c.DecryptOFB(e.Payload, unPadding)
+		}
+	default:
+		return nil, errors.New("错误的解密模式")
+	}
+}
+
+// SealString 使用当前密钥加密并封装为密文信封, 返回编码后的字符串
+//
+//	encode 编码方法, 如: base64.StdEncoding.EncodeToString
+func (kr *Keyring) SealString(data string, encode EncodeToString, opts ...SealOption) (string, error) {
+	sealed, err := kr.Seal([]byte(data), opts...)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return encode(sealed), nil
+}
+
+// OpenString 解码并解密 SealString 生成的密文信封
+//
+//	decode 解码方法, 与 SealString 的编码方法对应
+func (kr *Keyring) OpenString(sealed string, decode DecodeString, opts ...OpenOption) (string, error) {
+	data, err := decode(sealed)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	data, err = kr.Open(data, opts...)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return string(data), nil
+}
+
+// rawEncode 不编码, 用于直接获取 RSA 加密结果
+func rawEncode(b []byte) string {
+	return string(b)
+}
+
+// rawDecode 不解码, 用于直接解密 RSA 密文
+func rawDecode(s string) ([]byte, error) {
+	return []byte(s), nil
+}
@@ -0,0 +1,162 @@
+package utils_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/Is999/go-utils"
+)
+
+// newTestRSA 生成临时RSA密钥对
+func newTestRSA(t *testing.T) *utils.RSA {
+	t.Helper()
+	files, err := utils.GenerateKeyRSA(t.TempDir()+"/", 1024)
+	if err != nil {
+		t.Fatalf("GenerateKeyRSA() error = %v", err)
+	}
+	r, err := utils.NewRSA(files[0], files[1], utils.WithRSAFilePath(true))
+	if err != nil {
+		t.Fatalf("NewRSA() error = %v", err)
+	}
+	return r
+}
+
+func TestKeyringSeal(t *testing.T) {
+	kr, err := utils.NewKeyring(
+		&utils.Key{ID: "aes-2024", Type: utils.KeyAES, Secret: "1234567812345678"},
+		&utils.Key{ID: "aes-2025", Type: utils.KeyAES, Secret: "884100890d03e9f1efeda1b393ecba1b"},
+		&utils.Key{ID: "des", Type: utils.KeyDES, Secret: "12345678"},
+		&utils.Key{ID: "3des", Type: utils.KeyDES, Secret: "9F9CE8D28048399BA52A2E40"},
+		&utils.Key{ID: "rsa", Type: utils.KeyRSA, RSA: newTestRSA(t)},
+	)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	data := "Hello, 信封! 0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name     string
+		opts     []utils.SealOption
+		wantKey  string
+		wantMode utils.McryptMode
+		wantMAC  bool
+		wantErr  bool
+	}{
+		{name: "001", wantKey: "aes-2024", wantMode: utils.GCM},
+		{name: "002", opts: []utils.SealOption{utils.WithSealKey("aes-2025"), utils.WithSealMode(utils.CBC)}, wantKey: "aes-2025", wantMode: utils.CBC, wantMAC: true},
+		{name: "003", opts: []utils.SealOption{utils.WithSealMode(utils.ECB)}, wantKey: "aes-2024", wantMode: utils.ECB, wantMAC: true},
+		{name: "004", opts: []utils.SealOption{utils.WithSealMode(utils.CTR), utils.WithSealMAC(false)}, wantKey: "aes-2024", wantMode: utils.CTR},
+		{name: "005", opts: []utils.SealOption{utils.WithSealMode(utils.CFB)}, wantKey: "aes-2024", wantMode: utils.CFB, wantMAC: true},
+		{name: "006", opts: []utils.SealOption{utils.WithSealMode(utils.OFB)}, wantKey: "aes-2024", wantMode: utils.OFB, wantMAC: true},
+		{name: "007", opts: []utils.SealOption{utils.WithSealKey("des")}, wantKey: "des", wantMode: utils.CBC, wantMAC: true},
+		{name: "008", opts: []utils.SealOption{utils.WithSealKey("3des"), utils.WithSealMode(utils.OFB)}, wantKey: "3des", wantMode: utils.OFB, wantMAC: true},
+		{name: "009", opts: []utils.SealOption{utils.WithSealKey("rsa")}, wantKey: "rsa", wantMode: utils.ECB},
+		{name: "010", opts: []utils.SealOption{utils.WithSealKey("des"), utils.WithSealMode(utils.GCM)}, wantErr: true},
+		{name: "011", opts: []utils.SealOption{utils.WithSealKey("none")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := kr.SealString(data, base64.StdEncoding.EncodeToString, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SealString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			raw, _ := base64.StdEncoding.DecodeString(sealed)
+			e, err := utils.ParseEnvelope(raw)
+			if err != nil {
+				t.Fatalf("ParseEnvelope() error = %v", err)
+			}
+			if e.KeyID != tt.wantKey || e.Mode != tt.wantMode || (e.MAC != nil) != tt.wantMAC {
+				t.Errorf("ParseEnvelope() = %s %v mac=%v, want %s %v mac=%v", e.KeyID, e.Mode, e.MAC != nil, tt.wantKey, tt.wantMode, tt.wantMAC)
+			}
+
+			// 未经认证的密文需显式允许
+			var openOpts []utils.OpenOption
+			if !tt.wantMAC && tt.wantMode != utils.GCM && tt.wantKey != "rsa" {
+				if _, err := kr.OpenString(sealed, base64.StdEncoding.DecodeString); !errors.Is(err, utils.ErrEnvelopeInvalid) {
+					t.Errorf("OpenString() unauthenticated error = %v, want ErrEnvelopeInvalid", err)
+				}
+				openOpts = append(openOpts, utils.WithOpenAllowUnauthenticated(true))
+			}
+
+			got, err := kr.OpenString(sealed, base64.StdEncoding.DecodeString, openOpts...)
+			if err != nil || got != data {
+				t.Errorf("OpenString() = %q, %v, want %q", got, err, data)
+			}
+
+			// 篡改密文
+			if tt.wantMAC || tt.wantMode == utils.GCM {
+				raw[len(raw)-1] ^= 0x01
+				if _, err := kr.Open(raw); err == nil {
+					t.Errorf("Open() tampered error = nil")
+				}
+			}
+		})
+	}
+
+	t.Run("rotate", func(t *testing.T) {
+		old, _ := kr.Seal([]byte(data))
+		if err := kr.SetActive("aes-2025"); err != nil {
+			t.Fatalf("SetActive() error = %v", err)
+		}
+		sealed, _ := kr.Seal([]byte(data))
+		if e, _ := utils.ParseEnvelope(sealed); e.KeyID != "aes-2025" {
+			t.Errorf("Seal() key = %s, want aes-2025", e.KeyID)
+		}
+
+		// 历史数据仍可解密
+		for _, b := range [][]byte{old, sealed} {
+			if got, err := kr.Open(b); err != nil || string(got) != data {
+				t.Errorf("Open() = %q, %v", got, err)
+			}
+		}
+
+		// 密钥环中没有该密钥: 尝试其它已生效的密钥
+		other, _ := utils.NewKeyring(&utils.Key{ID: "other", Type: utils.KeyAES, Secret: "0D03E9F1EFEDA1B3"})
+		if _, err := other.Open(old); err == nil {
+			t.Errorf("Open() other key error = nil")
+		}
+		desSealed, _ := kr.Seal([]byte(data), utils.WithSealKey("des"))
+		if _, err := other.Open(desSealed); !errors.Is(err, utils.ErrKeyNotFound) {
+			t.Errorf("Open() error = %v, want ErrKeyNotFound", err)
+		}
+		renamed, _ := utils.NewKeyring(&utils.Key{ID: "renamed", Type: utils.KeyAES, Secret: "1234567812345678"})
+		if got, err := renamed.Open(old); err != nil || string(got) != data {
+			t.Errorf("Open() renamed key = %q, %v", got, err)
+		}
+	})
+
+	t.Run("strip MAC", func(t *testing.T) {
+		sealed, err := kr.Seal([]byte("hello"), utils.WithSealMode(utils.CBC))
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+
+		// 清除MAC标记并去掉MAC, 再篡改IV
+		stripped := append([]byte{}, sealed[:len(sealed)-32]...)
+		stripped[4] &^= 1
+		e, _ := utils.ParseEnvelope(stripped)
+		e.IV[0] ^= 0x01
+		tampered := e.Bytes()
+		for _, b := range [][]byte{stripped, tampered} {
+			if got, err := kr.Open(b); !errors.Is(err, utils.ErrEnvelopeInvalid) {
+				t.Errorf("Open() = %q, %v, want ErrEnvelopeInvalid", got, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, b := range [][]byte{nil, {2, 1, 5, 0, 0, 1, 'a', 0}, {1, 1, 5, 0, 0, 9, 'a'}, {1, 1, 1, 1, 1, 1, 'a', 0, 1}} {
+			if _, err := kr.Open(b); !errors.Is(err, utils.ErrEnvelopeInvalid) {
+				t.Errorf("Open(%v) error = %v, want ErrEnvelopeInvalid", b, err)
+			}
+		}
+		if _, err := utils.NewKeyring(&utils.Key{ID: "bad", Type: utils.KeyAES, Secret: "short"}); err == nil {
+			t.Errorf("NewKeyring() error = nil")
+		}
+	})
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"cmp"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -248,6 +249,8 @@ func (e *wrapError) GoString() string {
 	if e.err != nil {
 		if we, ok := (e.err).(*wrapError); ok {
 			b.WriteString(`,"err":` + we.GoString())
+		} else if raw, ok := marshalErr(e.err); ok {
+			b.WriteString(`,"err":` + string(raw))
 		} else {
 			b.WriteString(fmt.Sprintf(`,"err":%q`, e.err.Error()))
 		}
@@ -275,10 +278,25 @@ func (e *wrapError) logGroup() slog.Attr {
 	attr = append(attr, slog.Any("trace", e.stackTrace.LogValue()))
 	if we, ok := (e.err).(*wrapError); ok {
 		attr = append(attr, we.logGroup())
+	} else if lv, ok := (e.err).(slog.LogValuer); ok {
+		attr = append(attr, slog.Any("err", lv))
 	}
 	return slog.Group("wrap", attr...)
 }
 
+// marshalErr 子error实现了 json.Marshaler 接口时, 返回其JSON表示
+func marshalErr(err error) ([]byte, bool) {
+	m, ok := err.(json.Marshaler)
+	if !ok {
+		return nil, false
+	}
+	raw, e := m.MarshalJSON()
+	if e != nil || !json.Valid(raw) {
+		return nil, false
+	}
+	return raw, true
+}
+
 func callers(skip int) stackTrace {
 	var pcs [100]uintptr
 	n := runtime.Callers(skip+2, pcs[:])
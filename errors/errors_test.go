@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/Is999/go-utils/errors"
@@ -262,3 +263,23 @@ func TestErrorFormat(t *testing.T) {
 		t.Error("Error format with q returned empty string")
 	}
 }
+
+// jsonErr 实现 json.Marshaler 接口的 error
+type jsonErr struct{}
+
+func (jsonErr) Error() string                { return "json error" }
+func (jsonErr) MarshalJSON() ([]byte, error) { return []byte(`{"code":1}`), nil }
+
+func TestTraceJSONMarshaler(t *testing.T) {
+	err := errors.Wrap(jsonErr{}, "wrapped")
+
+	// 子error实现了 json.Marshaler 接口时输出其JSON
+	if s := errors.TraceJSON(err); !strings.Contains(s, `"err":{"code":1}`) {
+		t.Errorf("TraceJSON() = %v, want contains %v", s, `"err":{"code":1}`)
+	}
+
+	// 未实现时输出 Error()
+	if s := errors.TraceJSON(errors.Wrap(io.EOF, "wrapped")); !strings.Contains(s, `"err":"EOF"`) {
+		t.Errorf("TraceJSON() = %v, want contains %v", s, `"err":"EOF"`)
+	}
+}
@@ -0,0 +1,503 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Is999/go-utils/errors"
+)
+
+// ErrKeyNotFound 密钥环中没有可用的密钥
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyType 密钥类型
+type KeyType uint8
+
+const (
+	KeyAES KeyType = iota + 1 // 1 AES秘钥: 16、24或32字节
+	KeyDES                    // 2 DES秘钥: 8字节; 3DES秘钥: 24字节
+	KeyRSA                    // 3 RSA密钥对: 公钥加密、验证签名, 私钥解密、签名
+)
+
+// String 密钥类型名称
+func (t KeyType) String() string {
+	switch t {
+	case KeyAES:
+		return "AES"
+	case KeyDES:
+		return "DES"
+	case KeyRSA:
+		return "RSA"
+	default:
+		return "Unknown"
+	}
+}
+
+// Key 密钥环中的密钥
+type Key struct {
+	ID        string    // 密钥ID: 写入密文信封, 解密时据此选择密钥, 最长255字节
+	Type      KeyType   // 密钥类型
+	Secret    string    // 对称秘钥: KeyAES, KeyDES
+	RSA       *RSA      // RSA密钥对: KeyRSA
+	NotBefore time.Time // 生效时间: 零值立即生效; 生效前不用于加密、解密
+	NotAfter  time.Time // 过期时间: 零值永不过期; 过期后不再用于加密、签名, 仍可解密历史数据、验证签名
+}
+
+// Valid 密钥在t时间是否可用于加密、签名
+func (k *Key) Valid(t time.Time) bool {
+	return k.started(t) && (k.NotAfter.IsZero() || t.Before(k.NotAfter))
+}
+
+// started 密钥在t时间是否已生效, 已生效的密钥可用于解密、验证签名
+func (k *Key) started(t time.Time) bool {
+	return k.NotBefore.IsZero() || !t.Before(k.NotBefore)
+}
+
+// check 校验密钥, 错误信息不包含秘钥内容
+func (k *Key) check() error {
+	if k.ID == "" || len(k.ID) > 255 {
+		return errors.Errorf("密钥ID长度只能是1-255字节, 当前长度: %d", len(k.ID))
+	}
+	switch k.Type {
+	case KeyAES:
+		if l := len(k.Secret); l != 16 && l != 24 && l != 32 {
+			return errors.Errorf("密钥[%s] AES秘钥的长度只能是16、24或32字节, 当前长度: %d", k.ID, l)
+		}
+	case KeyDES:
+		if l := len(k.Secret); l != 8 && l != 24 {
+			return errors.Errorf("密钥[%s] DES秘钥的长度只能是8字节, 3DES秘钥的长度只能是24字节, 当前长度: %d", k.ID, l)
+		}
+	case KeyRSA:
+		if k.RSA == nil || (k.RSA.IsSetPublicKey() != nil && k.RSA.IsSetPrivateKey() != nil) {
+			return errors.Errorf("密钥[%s] 未设置RSA公钥或私钥", k.ID)
+		}
+	default:
+		return errors.Errorf("密钥[%s] 不支持的密钥类型: %d", k.ID, k.Type)
+	}
+	return nil
+}
+
+// Cipher 使用对称秘钥创建加密器
+//
+//	Cipher 加密时会修改IV, 不能并发使用, 每次调用返回新的实例
+func (k *Key) Cipher(opts ...CipherOption) (*Cipher, error) {
+	switch k.Type {
+	case KeyAES:
+		return AES(k.Secret, opts...)
+	case KeyDES:
+		return DES(k.Secret, opts...)
+	default:
+		return nil, errors.Errorf("密钥[%s] %s 不是对称秘钥", k.ID, k.Type)
+	}
+}
+
+// Keyring 密钥环: 按密钥ID管理多个密钥, 使用当前密钥加密、签名, 使用所有已生效的密钥解密、验证签名
+//
+//	当前密钥: SetActive 指定且在有效期内的密钥; 未指定或已过期时为有效期内生效时间最晚的密钥(相同时为先添加的)
+//	可并发使用
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	order   []string              // 密钥ID添加顺序
+	pinned  string                // SetActive 指定的密钥ID
+	current *Key                  // 最近一次确定的当前密钥, 变化时执行轮换回调
+	hooks   []func(old, new *Key) // 轮换回调
+}
+
+// NewKeyring 实例化Keyring并添加密钥
+func NewKeyring(keys ...*Key) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if err := kr.Add(key); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	return kr, nil
+}
+
+// Add 添加密钥, 密钥ID已存在时返回错误
+func (kr *Keyring) Add(key *Key) error {
+	if key == nil {
+		return errors.New("密钥不能为nil")
+	}
+	if err := key.check(); err != nil {
+		return errors.Wrap(err)
+	}
+
+	kr.mu.Lock()
+	if _, ok := kr.keys[key.ID]; ok {
+		kr.mu.Unlock()
+		return errors.Errorf("密钥[%s]已存在", key.ID)
+	}
+	kr.set(key)
+	kr.mu.Unlock()
+
+	kr.Refresh()
+	return nil
+}
+
+// set 添加或替换密钥(需持有写锁)
+func (kr *Keyring) set(key *Key) {
+	if _, ok := kr.keys[key.ID]; !ok {
+		kr.order = append(kr.order, key.ID)
+	}
+	kr.keys[key.ID] = key
+}
+
+// Remove 删除密钥, 删除后无法解密使用该密钥加密的数据
+func (kr *Keyring) Remove(id string) {
+	kr.mu.Lock()
+	if _, ok := kr.keys[id]; ok {
+		delete(kr.keys, id)
+		kr.order = slices.DeleteFunc(kr.order, func(v string) bool { return v == id })
+	}
+	if kr.pinned == id {
+		kr.pinned = ""
+	}
+	kr.mu.Unlock()
+
+	kr.Refresh()
+}
+
+// Rotate 添加密钥并设为当前密钥, 旧密钥保留用于解密历史数据
+func (kr *Keyring) Rotate(key *Key) error {
+	if err := kr.Add(key); err != nil {
+		return errors.Wrap(err)
+	}
+	return kr.SetActive(key.ID)
+}
+
+// SetActive 指定当前密钥, 用于加密、签名; 设置为空字符串取消指定
+func (kr *Keyring) SetActive(id string) error {
+	kr.mu.Lock()
+	if _, ok := kr.keys[id]; !ok && id != "" {
+		kr.mu.Unlock()
+		return errors.Wrapf(ErrKeyNotFound, "id=%s", id)
+	}
+	kr.pinned = id
+	kr.mu.Unlock()
+
+	kr.Refresh()
+	return nil
+}
+
+// Active 获取当前密钥
+//
+//	types 限定密钥类型, 如: Active(KeyRSA) 获取当前RSA密钥
+func (kr *Keyring) Active(types ...KeyType) (*Key, error) {
+	now := time.Now()
+	kr.mu.RLock()
+	key := kr.active(now, func(k *Key) bool {
+		return len(types) == 0 || slices.Contains(types, k.Type)
+	})
+	changed := kr.active(now, nil) != kr.current
+	kr.mu.RUnlock()
+
+	// 密钥到达生效或过期时间
+	if changed {
+		kr.Refresh()
+	}
+
+	if key == nil {
+		return nil, errors.Wrap(ErrKeyNotFound, "没有有效的密钥")
+	}
+	return key, nil
+}
+
+// active 选择当前密钥(需持有锁), match 为nil时不限制
+func (kr *Keyring) active(now time.Time, match func(k *Key) bool) *Key {
+	if k, ok := kr.keys[kr.pinned]; ok && k.Valid(now) && (match == nil || match(k)) {
+		return k
+	}
+
+	var active *Key
+	for _, id := range kr.order {
+		k := kr.keys[id]
+		if !k.Valid(now) || (match != nil && !match(k)) {
+			continue
+		}
+		if active == nil || k.NotBefore.After(active.NotBefore) {
+			active = k
+		}
+	}
+	return active
+}
+
+// Key 按密钥ID获取密钥
+func (kr *Keyring) Key(id string) (*Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrKeyNotFound, "id=%s", id)
+	}
+	return key, nil
+}
+
+// Keys 获取所有密钥, 按添加顺序
+func (kr *Keyring) Keys() []*Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	keys := make([]*Key, 0, len(kr.order))
+	for _, id := range kr.order {
+		keys = append(keys, kr.keys[id])
+	}
+	return keys
+}
+
+// candidates 可用于解密、验证签名的密钥: 指定ID的密钥优先, 其余按生效时间从晚到早
+func (kr *Keyring) candidates(id string, match func(k *Key) bool) []*Key {
+	now := time.Now()
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(kr.order))
+	for _, v := range kr.order {
+		if k := kr.keys[v]; k.started(now) && match(k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortStableFunc(keys, func(a, b *Key) int {
+		if (a.ID == id) != (b.ID == id) {
+			return Ternary(a.ID == id, -1, 1)
+		}
+		return b.NotBefore.Compare(a.NotBefore)
+	})
+	return keys
+}
+
+// OnRotate 添加轮换回调: 当前密钥变化时执行, 如 SetActive、Rotate 或密钥到达生效、过期时间
+//
+//	old 为nil表示之前没有当前密钥, new 为nil表示没有有效的密钥; 多次调用不会覆盖已添加的回调
+func (kr *Keyring) OnRotate(f func(old, new *Key)) *Keyring {
+	if f != nil {
+		kr.mu.Lock()
+		kr.hooks = append(kr.hooks, f)
+		kr.mu.Unlock()
+	}
+	return kr
+}
+
+// Refresh 重新确定当前密钥, 当前密钥变化时执行轮换回调
+func (kr *Keyring) Refresh() {
+	kr.mu.Lock()
+	old, active := kr.current, kr.active(time.Now(), nil)
+	if old == active {
+		kr.mu.Unlock()
+		return
+	}
+	kr.current = active
+	hooks := slices.Clone(kr.hooks)
+	kr.mu.Unlock()
+
+	for _, f := range hooks {
+		f(old, active)
+	}
+}
+
+// StartRotation 定时检查密钥有效期, 当前密钥变化时执行轮换回调; ctx 取消时停止
+//
+//	interval 检查间隔: 必须大于0
+//	reload 每次检查前重新加载密钥, 如: func(kr *Keyring) error { return kr.LoadFile(path) }; 可为nil
+func (kr *Keyring) StartRotation(ctx context.Context, interval time.Duration, reload func(kr *Keyring) error) error {
+	if interval <= 0 {
+		return errors.Errorf("检查间隔必须大于0: %v", interval)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if reload != nil {
+				if err := reload(kr); err != nil {
+					Log().Error("Keyring reload", "err", err.Error()) // Error 日志
+				}
+			}
+			kr.Refresh()
+		}
+	}()
+	return nil
+}
+
+// Sign 使用当前RSA私钥签名, 返回签名串及密钥ID
+//
+//	hash 加密哈希函数标识, 如: crypto.SHA256
+//	encode 编码方法
+func (kr *Keyring) Sign(data string, hash crypto.Hash, encode EncodeToString) (sign, keyID string, err error) {
+	now := time.Now()
+	kr.mu.RLock()
+	key := kr.active(now, func(k *Key) bool {
+		return k.Type == KeyRSA && k.RSA.IsSetPrivateKey() == nil
+	})
+	kr.mu.RUnlock()
+	if key == nil {
+		return "", "", errors.Wrap(ErrKeyNotFound, "没有有效的RSA私钥")
+	}
+
+	sign, err = key.RSA.Sign(data, hash, encode)
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	return sign, key.ID, nil
+}
+
+// Verify 验证签名: 依次尝试所有已生效的RSA公钥(含已过期的), 任一验证通过即返回nil
+//
+//	keyID 签名使用的密钥ID, 优先尝试该密钥; 可为空
+func (kr *Keyring) Verify(data, sign string, hash crypto.Hash, decode DecodeString, keyID ...string) error {
+	var id string
+	if len(keyID) > 0 {
+		id = keyID[0]
+	}
+	keys := kr.candidates(id, func(k *Key) bool {
+		return k.Type == KeyRSA && k.RSA.IsSetPublicKey() == nil
+	})
+	if len(keys) == 0 {
+		return errors.Wrap(ErrKeyNotFound, "没有已生效的RSA公钥")
+	}
+
+	var err error
+	for _, key := range keys {
+		if err = key.RSA.Verify(data, sign, hash, decode); err == nil {
+			return nil
+		}
+	}
+	return errors.Wrap(err)
+}
+
+// KeyConfig 密钥配置, 用于从文件或环境变量加载密钥
+type KeyConfig struct {
+	ID         string    `json:"id"`          // 密钥ID
+	Type       string    `json:"type"`        // 密钥类型: aes, des, rsa
+	Secret     string    `json:"secret"`      // 对称秘钥: 原始内容, 或 base64:、hex: 前缀的编码内容
+	PublicKey  string    `json:"public_key"`  // RSA公钥: PEM内容或文件路径
+	PrivateKey string    `json:"private_key"` // RSA私钥: PEM内容或文件路径
+	NotBefore  time.Time `json:"not_before"`  // 生效时间: RFC3339格式, 如 2025-01-01T00:00:00Z
+	NotAfter   time.Time `json:"not_after"`   // 过期时间: RFC3339格式
+	Active     bool      `json:"active"`      // 指定为当前密钥
+}
+
+// Key 根据配置创建密钥
+func (c *KeyConfig) Key() (*Key, error) {
+	key := &Key{ID: c.ID, NotBefore: c.NotBefore, NotAfter: c.NotAfter}
+
+	switch strings.ToLower(c.Type) {
+	case "aes":
+		key.Type = KeyAES
+	case "des", "3des":
+		key.Type = KeyDES
+	case "rsa":
+		key.Type = KeyRSA
+	default:
+		return nil, errors.Errorf("密钥[%s] 不支持的密钥类型: %s", c.ID, c.Type)
+	}
+
+	if key.Type == KeyRSA {
+		key.RSA = &RSA{}
+		if c.PublicKey != "" {
+			if err := key.RSA.SetPublicKey(c.PublicKey, IsFile(c.PublicKey)); err != nil {
+				return nil, errors.Wrapf(err, "密钥[%s] 公钥错误", c.ID)
+			}
+		}
+		if c.PrivateKey != "" {
+			if err := key.RSA.SetPrivateKey(c.PrivateKey, IsFile(c.PrivateKey)); err != nil {
+				return nil, errors.Wrapf(err, "密钥[%s] 私钥错误", c.ID)
+			}
+		}
+	} else {
+		secret, err := decodeSecret(c.Secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "密钥[%s] 秘钥解码失败", c.ID)
+		}
+		key.Secret = secret
+	}
+
+	if err := key.check(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return key, nil
+}
+
+// decodeSecret 解码 base64:、hex: 前缀的秘钥
+func decodeSecret(secret string) (string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch {
+	case strings.HasPrefix(secret, "base64:"):
+		b, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "base64:"))
+	case strings.HasPrefix(secret, "hex:"):
+		b, err = hex.DecodeString(strings.TrimPrefix(secret, "hex:"))
+	default:
+		return secret, nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return string(b), nil
+}
+
+// Load 加载密钥配置: 密钥ID已存在时替换该密钥; 任一配置错误时不加载
+func (kr *Keyring) Load(configs ...KeyConfig) error {
+	keys := make([]*Key, 0, len(configs))
+	for i := range configs {
+		key, err := configs[i].Key()
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		keys = append(keys, key)
+	}
+
+	kr.mu.Lock()
+	for i, key := range keys {
+		kr.set(key)
+		if configs[i].Active {
+			kr.pinned = key.ID
+		}
+	}
+	kr.mu.Unlock()
+
+	kr.Refresh()
+	return nil
+}
+
+// LoadFile 从JSON文件加载密钥配置, 文件内容为 KeyConfig 数组
+func (kr *Keyring) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(kr.loadJSON(data))
+}
+
+// LoadEnv 从环境变量加载密钥配置, 环境变量值为 KeyConfig 数组的JSON
+func (kr *Keyring) LoadEnv(key string) error {
+	data := GetEnv(key)
+	if data == "" {
+		return errors.Errorf("环境变量[%s]未设置", key)
+	}
+	return errors.Wrap(kr.loadJSON([]byte(data)))
+}
+
+// loadJSON 解析JSON格式的密钥配置并加载
+func (kr *Keyring) loadJSON(data []byte) error {
+	var configs []KeyConfig
+	if err := Unmarshal(data, &configs); err != nil {
+		return errors.Wrap(err)
+	}
+	return kr.Load(configs...)
+}
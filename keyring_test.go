@@ -0,0 +1,191 @@
+package utils_test
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Is999/go-utils"
+)
+
+func TestKeyringActive(t *testing.T) {
+	now := time.Now()
+	kr, err := utils.NewKeyring(
+		&utils.Key{ID: "k1", Type: utils.KeyAES, Secret: "1234567812345678", NotAfter: now.Add(time.Hour)},
+		&utils.Key{ID: "k2", Type: utils.KeyAES, Secret: "0D03E9F1EFEDA1B3", NotBefore: now.Add(-time.Minute)},
+		&utils.Key{ID: "k3", Type: utils.KeyAES, Secret: "884100890d03e9f1efeda1b393ecba1b", NotBefore: now.Add(time.Hour)},
+		&utils.Key{ID: "expired", Type: utils.KeyDES, Secret: "12345678", NotAfter: now.Add(-time.Minute)},
+	)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		rotation []string
+	)
+	kr.OnRotate(func(old, new *utils.Key) {
+		mu.Lock()
+		defer mu.Unlock()
+		rotation = append(rotation, old.ID+"->"+new.ID)
+	})
+
+	tests := []struct {
+		name    string
+		active  string
+		types   []utils.KeyType
+		want    string
+		wantErr bool
+	}{
+		{name: "001", want: "k2"},                                          // 已生效且生效时间最晚
+		{name: "002", active: "k1", want: "k1"},                            // 指定当前密钥
+		{name: "003", active: "k3", want: "k2"},                            // 指定的密钥未生效
+		{name: "004", active: "expired", want: "k2"},                       // 指定的密钥已过期
+		{name: "005", types: []utils.KeyType{utils.KeyDES}, wantErr: true}, // 没有有效的DES密钥
+		{name: "006", active: "none", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.active != "" {
+				if err := kr.SetActive(tt.active); err != nil {
+					if !tt.wantErr {
+						t.Fatalf("SetActive() error = %v", err)
+					}
+					return
+				}
+				defer kr.SetActive("")
+			}
+			key, err := kr.Active(tt.types...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Active() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && key.ID != tt.want {
+				t.Errorf("Active() = %s, want %s", key.ID, tt.want)
+			}
+		})
+	}
+
+	mu.Lock()
+	got := append([]string(nil), rotation...)
+	mu.Unlock()
+	if want := []string{"k2->k1", "k1->k2"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("OnRotate() = %v, want %v", got, want)
+	}
+
+	// 未生效的密钥不用于解密
+	sealed, _ := kr.Seal([]byte("data"))
+	kr2, _ := utils.NewKeyring(&utils.Key{ID: "k2", Type: utils.KeyAES, Secret: "0D03E9F1EFEDA1B3", NotBefore: now.Add(time.Hour)})
+	if _, err := kr2.Open(sealed); !errors.Is(err, utils.ErrKeyNotFound) {
+		t.Errorf("Open() error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestKeyringLoad(t *testing.T) {
+	files, err := utils.GenerateKeyRSA(t.TempDir()+"/", 1024)
+	if err != nil {
+		t.Fatalf("GenerateKeyRSA() error = %v", err)
+	}
+	pub, _ := os.ReadFile(files[0])
+
+	t.Setenv("TEST_KEYRING", `[
+		{"id":"aes-1","type":"aes","secret":"base64:`+base64.StdEncoding.EncodeToString([]byte("1234567812345678"))+`"},
+		{"id":"aes-2","type":"AES","secret":"hex:30443033453946314546454441314233","active":true},
+		{"id":"rsa-1","type":"rsa","public_key":"`+files[0]+`","private_key":"`+files[1]+`","not_after":"2000-01-01T00:00:00Z"}
+	]`)
+
+	kr, _ := utils.NewKeyring()
+	if err := kr.LoadEnv("TEST_KEYRING"); err != nil {
+		t.Fatalf("LoadEnv() error = %v", err)
+	}
+	if key, err := kr.Active(); err != nil || key.ID != "aes-2" || key.Secret != "0D03E9F1EFEDA1B3" {
+		t.Errorf("Active() = %v, %v, want aes-2", key, err)
+	}
+	if err := kr.LoadEnv("TEST_KEYRING_NONE"); err == nil {
+		t.Errorf("LoadEnv() error = nil")
+	}
+
+	// 已过期的RSA密钥不用于签名, 仍可验证签名
+	if _, _, err := kr.Sign("data", crypto.SHA256, base64.StdEncoding.EncodeToString); !errors.Is(err, utils.ErrKeyNotFound) {
+		t.Errorf("Sign() error = %v, want ErrKeyNotFound", err)
+	}
+	signer, _ := utils.NewPriRSA(files[1], utils.WithRSAFilePath(true))
+	sign, _ := signer.Sign("data", crypto.SHA256, base64.StdEncoding.EncodeToString)
+	if err := kr.Verify("data", sign, crypto.SHA256, base64.StdEncoding.DecodeString); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// 从文件加载: 新的RSA密钥(PEM内容)替换当前签名密钥
+	path := filepath.Join(t.TempDir(), "keys.json")
+	newFiles, _ := utils.GenerateKeyRSA(t.TempDir()+"/", 1024)
+	newPri, _ := os.ReadFile(newFiles[1])
+	newPub, _ := os.ReadFile(newFiles[0])
+	b, _ := utils.Marshal([]utils.KeyConfig{{ID: "rsa-2", Type: "rsa", PublicKey: string(newPub), PrivateKey: string(newPri)}})
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := kr.StartRotation(ctx, 0, nil); err == nil {
+		t.Errorf("StartRotation() interval = 0 error = nil")
+	}
+	err = kr.StartRotation(ctx, 10*time.Millisecond, func(kr *utils.Keyring) error {
+		if err := kr.LoadFile(path); err != nil {
+			return err
+		}
+		select {
+		case rotated <- "loaded":
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StartRotation() error = %v", err)
+	}
+	select {
+	case <-rotated:
+	case <-time.After(time.Second):
+		t.Fatal("StartRotation() reload not called")
+	}
+
+	sign, keyID, err := kr.Sign("data", crypto.SHA256, base64.StdEncoding.EncodeToString)
+	if err != nil || keyID != "rsa-2" {
+		t.Fatalf("Sign() = %s, %v, want rsa-2", keyID, err)
+	}
+	for _, id := range []string{keyID, "rsa-1", ""} {
+		if err := kr.Verify("data", sign, crypto.SHA256, base64.StdEncoding.DecodeString, id); err != nil {
+			t.Errorf("Verify(%q) error = %v", id, err)
+		}
+	}
+
+	// 只有公钥的RSA密钥可以加密, 不能解密
+	pubOnly, _ := utils.NewKeyring()
+	if err := pubOnly.Load(utils.KeyConfig{ID: "rsa-1", Type: "rsa", PublicKey: string(pub)}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	sealed, err := pubOnly.Seal([]byte("data"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := pubOnly.Open(sealed); err == nil {
+		t.Errorf("Open() without private key error = nil")
+	}
+	if got, err := kr.Open(sealed); err != nil || string(got) != "data" {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+
+	// 配置错误时不加载
+	if err := kr.Load(utils.KeyConfig{ID: "bad", Type: "aes", Secret: "hex:zz"}); err == nil {
+		t.Errorf("Load() error = nil")
+	}
+	if _, err := kr.Key("bad"); !errors.Is(err, utils.ErrKeyNotFound) {
+		t.Errorf("Key() error = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math/rand/v2"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -182,5 +183,18 @@ func UniqId(l uint8, r ...*rand.Rand) string {
 	return b.String()
 }
 
-// RandSource rand
-var RandSource = rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), uint64(time.Now().UnixNano())))
+// RandSource rand, 可并发使用
+var RandSource = rand.New(&lockedSource{src: rand.NewPCG(uint64(time.Now().UnixNano()), uint64(time.Now().UnixNano()))})
+
+// lockedSource 并发安全的 rand.Source
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+// Uint64 实现 rand.Source 接口
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}